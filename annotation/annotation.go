@@ -0,0 +1,291 @@
+// Package annotation 解析控制器与处理函数文档注释中的注解.
+//
+// 语法:
+//
+//	doc        = { line } .
+//	annotation = "@" name { arg } .
+//	arg        = value | key "=" value .
+//	value      = word | quoted .
+//	quoted     = `"` { char } `"` .
+//
+// word 为不含空白的字符序列, 括号内可以包含空白, 如 RateLimit(10, 60), Pair[string, int];
+// quoted 遵循 Go 字符串字面量的转义规则; 行尾的 \ 表示注解在下一行继续.
+// 不以 @ 开头的行视为普通注释, 会被忽略.
+//
+// @Summary, @Desc 等自由文本注解不拆分参数, 注解名之后的整段文本即为其内容,
+// 文本中的引号与括号不需要成对出现.
+package annotation
+
+import (
+	"go/scanner"
+	"go/token"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Arg 注解参数, 位置参数的 Key 为空
+type Arg struct {
+	Key   string
+	Value string
+	// Quoted 值以引号形式书写
+	Quoted bool
+	Pos    token.Position
+}
+
+// Annotation 一条注解
+type Annotation struct {
+	Name string
+	Args []Arg
+	// Text 注解名之后的原始文本, 续行已合并
+	Text string
+	Pos  token.Position
+	// Err 参数无法拆分时的错误, 此时 Args 为空
+	Err *scanner.Error
+}
+
+// prose 自由文本注解, 不拆分参数
+var prose = map[string]bool{
+	"Summary":        true,
+	"Desc":           true,
+	"Description":    true,
+	"Title":          true,
+	"Version":        true,
+	"TermsOfService": true,
+	"Host":           true,
+	"BasePath":       true,
+	"Controller":     true,
+}
+
+// Positional 返回所有位置参数
+func (a Annotation) Positional() (result []string) {
+	for _, v := range a.Args {
+		if v.Key == "" {
+			result = append(result, v.Value)
+		}
+	}
+	return result
+}
+
+// Option 返回 key=value 形式的参数
+func (a Annotation) Option(key string) (string, bool) {
+	for _, v := range a.Args {
+		if v.Key == key {
+			return v.Value, true
+		}
+	}
+	return "", false
+}
+
+// Line 文档注释中的一行, 已去掉注释符号与首尾空白, Pos 为内容在源文件中的起始位置
+type Line struct {
+	Text string
+	Pos  token.Position
+}
+
+// Strings 文档注释各行的文本
+func Strings(doc []Line) []string {
+	var result []string
+	for _, v := range doc {
+		result = append(result, v.Text)
+	}
+	return result
+}
+
+// line 注解所在的一行, pos 为 text 在源文件中的起始位置
+type line struct {
+	text string
+	pos  token.Position
+}
+
+// Parse 解析文档注释, 参数有误的注解同样会返回, 其 Err 记录了错误
+func Parse(doc []Line) ([]Annotation, error) {
+	var errs scanner.ErrorList
+	var result []Annotation
+	lines := make([]line, len(doc))
+	for i, v := range doc {
+		lines[i] = line{text: v.Text, pos: v.Pos}
+	}
+	for i := 0; i < len(lines); i++ {
+		l := lines[i]
+		if !strings.HasPrefix(l.text, "@") {
+			continue
+		}
+		segments := []line{l}
+		for strings.HasSuffix(l.text, `\`) && i+1 < len(lines) {
+			i++
+			l = lines[i]
+			segments = append(segments, l)
+		}
+		var parts []string
+		for j := range segments {
+			segments[j].text = strings.TrimSpace(strings.TrimSuffix(segments[j].text, `\`))
+			parts = append(parts, segments[j].text)
+		}
+		a, err := parseLine(strings.Join(parts, " "), segments)
+		if err != nil {
+			a.Args = nil
+			a.Err = err.(*scanner.Error)
+			errs = append(errs, a.Err)
+		}
+		result = append(result, a)
+	}
+	return result, errs.Err()
+}
+
+// at 计算合并后文本中第 offset 个字节在源文件中的位置
+func at(segments []line, offset int) token.Position {
+	for _, s := range segments {
+		if offset <= len(s.text) {
+			p := s.pos
+			p.Column += offset
+			return p
+		}
+		offset -= len(s.text) + 1
+	}
+	return segments[len(segments)-1].pos
+}
+
+func parseLine(text string, segments []line) (a Annotation, err error) {
+	a.Pos = segments[0].pos
+	i := 1
+	for i < len(text) && (unicode.IsLetter(rune(text[i])) || unicode.IsDigit(rune(text[i])) || text[i] == '_') {
+		i++
+	}
+	a.Name = text[1:i]
+	if a.Name == "" {
+		return a, &scanner.Error{Pos: a.Pos, Msg: "missing annotation name after @"}
+	}
+	a.Text = strings.TrimSpace(text[i:])
+	if prose[a.Name] {
+		return a, nil
+	}
+
+	for {
+		for i < len(text) && (text[i] == ' ' || text[i] == '\t') {
+			i++
+		}
+		if i >= len(text) {
+			break
+		}
+		arg := Arg{Pos: at(segments, i), Quoted: text[i] == '"'}
+		word, next, err := scanWord(text, i, segments, true)
+		if err != nil {
+			return a, err
+		}
+		i = next
+		if i < len(text) && text[i] == '=' {
+			arg.Key = word
+			arg.Quoted = i+1 < len(text) && text[i+1] == '"'
+			word, i, err = scanWord(text, i+1, segments, false)
+			if err != nil {
+				return a, err
+			}
+			if word == "" {
+				return a, &scanner.Error{Pos: at(segments, i), Msg: "missing value for option " + arg.Key}
+			}
+		}
+		arg.Value = word
+		a.Args = append(a.Args, arg)
+	}
+	return a, nil
+}
+
+// keyEnd 判断 text[start:end] 是否为 key= 的形式, 返回 = 的位置
+func keyEnd(text string, start, end int) int {
+	for i := start; i < end; i++ {
+		c := text[i]
+		if c == '=' {
+			if i == start {
+				return -1
+			}
+			return i
+		}
+		if !(unicode.IsLetter(rune(c)) || unicode.IsDigit(rune(c)) || c == '_' || c == '-') {
+			return -1
+		}
+	}
+	return -1
+}
+
+// scanWord 从 i 开始读取一个值, 返回值与下一个读取位置; key 为 true 时遇到 key= 会停在 = 处.
+// 括号不成对时读取到行尾, 由使用该值的注解检查, 见 balanced
+func scanWord(text string, i int, segments []line, key bool) (string, int, error) {
+	if i < len(text) && text[i] == '"' {
+		end := i + 1
+		for end < len(text) && text[end] != '"' {
+			if text[end] == '\\' {
+				end++
+			}
+			end++
+		}
+		if end >= len(text) {
+			return "", end, &scanner.Error{Pos: at(segments, i), Msg: "unterminated quoted string"}
+		}
+		value, err := strconv.Unquote(text[i : end+1])
+		if err != nil {
+			return "", end, &scanner.Error{Pos: at(segments, i), Msg: "invalid quoted string: " + err.Error()}
+		}
+		return value, end + 1, nil
+	}
+
+	start := i
+	depth := 0
+	for i < len(text) {
+		c := text[i]
+		if depth == 0 && (c == ' ' || c == '\t') {
+			break
+		}
+		if key && depth == 0 && c == '=' && keyEnd(text, start, i+1) > 0 {
+			break
+		}
+		switch c {
+		case '(', '[':
+			depth++
+		case ')', ']':
+			if depth > 0 {
+				depth--
+			}
+		case '"':
+			if depth > 0 {
+				for i++; i < len(text) && text[i] != '"'; i++ {
+					if text[i] == '\\' {
+						i++
+					}
+				}
+			}
+		}
+		i++
+	}
+	return text[start:i], i, nil
+}
+
+// balanced 类型表达式与中间件调用中的括号是否成对
+func balanced(s string) bool {
+	var stack []byte
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '(', '[':
+			stack = append(stack, c)
+		case ')', ']':
+			open := byte('(')
+			if c == ']' {
+				open = '['
+			}
+			if len(stack) == 0 || stack[len(stack)-1] != open {
+				return false
+			}
+			stack = stack[:len(stack)-1]
+		case '"':
+			for i++; i < len(s) && s[i] != '"'; i++ {
+				if s[i] == '\\' {
+					i++
+				}
+			}
+			if i >= len(s) {
+				return false
+			}
+		}
+	}
+	return len(stack) == 0
+}
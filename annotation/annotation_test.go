@@ -0,0 +1,405 @@
+package annotation
+
+import (
+	"go/token"
+	"reflect"
+	"testing"
+)
+
+// doc 构造文档注释, 每行内容从第 4 列开始, 即 "// " 之后
+func doc(lines ...string) []Line {
+	result := make([]Line, len(lines))
+	for i, v := range lines {
+		result[i] = Line{Text: v, Pos: token.Position{Filename: "a.go", Line: i + 1, Column: 4}}
+	}
+	return result
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  []Line
+		// args 位置参数与 key=value 参数, key= 形式的值写作 key=value
+		args []string
+		text string
+		err  string
+	}{
+		{
+			name: "path",
+			doc:  doc("@GetApi /user/:id"),
+			args: []string{"/user/:id"},
+			text: "/user/:id",
+		},
+		{
+			name: "brackets keep spaces",
+			doc:  doc("@Middleware RateLimit(10, 60) Auth"),
+			args: []string{"RateLimit(10, 60)", "Auth"},
+			text: "RateLimit(10, 60) Auth",
+		},
+		{
+			name: "generic type and quoted description",
+			doc:  doc(`@Response 200 Pair[string, int] "用户 列表"`),
+			args: []string{"200", "Pair[string, int]", "用户 列表"},
+			text: `200 Pair[string, int] "用户 列表"`,
+		},
+		{
+			name: "option",
+			doc:  doc(`@Server https://api.example.com "生产环境" env=prod`),
+			args: []string{"https://api.example.com", "生产环境", "env=prod"},
+			text: `https://api.example.com "生产环境" env=prod`,
+		},
+		{
+			name: "quoted option",
+			doc:  doc(`@Contact 张三 url="https://a.com/x y"`),
+			args: []string{"张三", "url=https://a.com/x y"},
+			text: `张三 url="https://a.com/x y"`,
+		},
+		{
+			name: "equal sign inside brackets",
+			doc:  doc("@Middleware Limit(rate=1)"),
+			args: []string{"Limit(rate=1)"},
+			text: "Limit(rate=1)",
+		},
+		{
+			name: "escaped quote",
+			doc:  doc(`@Tag "a\"b"`),
+			args: []string{`a"b`},
+			text: `"a\"b"`,
+		},
+		{
+			name: "continuation",
+			doc:  doc(`@Response 200 UserList \`, `"用户列表"`),
+			args: []string{"200", "UserList", "用户列表"},
+			text: `200 UserList "用户列表"`,
+		},
+		{
+			name: "prose keeps quotes and brackets",
+			doc:  doc(`@Summary 返回 "ok 或错误 (按 id`),
+			text: `返回 "ok 或错误 (按 id`,
+		},
+		{
+			name: "unbalanced brackets read to the end",
+			doc:  doc("@Response 200 Page[User"),
+			args: []string{"200", "Page[User"},
+			text: "200 Page[User",
+		},
+		{
+			name: "unterminated quote",
+			doc:  doc(`@Tag "abc`),
+			text: `"abc`,
+			err:  "a.go:1:9: unterminated quoted string",
+		},
+		{
+			name: "invalid escape",
+			doc:  doc(`@Tag "a\qb"`),
+			text: `"a\qb"`,
+			err:  "a.go:1:9: invalid quoted string: invalid syntax",
+		},
+		{
+			name: "missing option value",
+			doc:  doc("@Server /api env="),
+			text: "/api env=",
+			err:  "a.go:1:21: missing value for option env",
+		},
+		{
+			name: "error on continuation line",
+			doc:  doc(`@Tag a \`, `"b`),
+			text: `a "b`,
+			err:  "a.go:2:4: unterminated quoted string",
+		},
+		{
+			name: "missing name",
+			doc:  doc("@ GetApi"),
+			err:  "a.go:1:4: missing annotation name after @",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			list, err := Parse(tt.doc)
+			if len(list) != 1 {
+				t.Fatalf("got %d annotations, want 1", len(list))
+			}
+			a := list[0]
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("err = %v, want %s", err, tt.err)
+				}
+				if a.Err == nil || a.Args != nil {
+					t.Fatalf("annotation should keep the error and have no args, got %+v", a)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var args []string
+			for _, v := range a.Args {
+				if v.Key != "" {
+					args = append(args, v.Key+"="+v.Value)
+					continue
+				}
+				args = append(args, v.Value)
+			}
+			if !reflect.DeepEqual(args, tt.args) {
+				t.Errorf("args = %q, want %q", args, tt.args)
+			}
+			if a.Text != tt.text {
+				t.Errorf("text = %q, want %q", a.Text, tt.text)
+			}
+		})
+	}
+}
+
+func TestParseArgPos(t *testing.T) {
+	list, err := Parse(doc(`@Response 200 \`, `UserList "列表"`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, v := range list[0].Args {
+		got = append(got, v.Pos.String())
+	}
+	want := []string{"a.go:1:14", "a.go:2:4", "a.go:2:13"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("positions = %q, want %q", got, want)
+	}
+}
+
+func TestParseIgnoresComments(t *testing.T) {
+	list, err := Parse(doc("Get 获取用户", "", "@GetApi /user", "email@example.com"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].Name != "GetApi" {
+		t.Errorf("got %+v, want only @GetApi", list)
+	}
+}
+
+func TestParseHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		doc        []Line
+		method     string
+		path       string
+		summary    string
+		middleware []string
+		responses  int
+		err        string
+		// docErrs Handler.Errors 中的错误
+		docErrs []string
+	}{
+		{
+			name:      "route",
+			doc:       doc("@GetApi /user/:id", "@Summary 用户详情", `@Response 200 User "用户"`, "@Response 404"),
+			method:    "GET",
+			path:      "/user/:id",
+			summary:   "用户详情",
+			responses: 2,
+		},
+		{
+			name:    "stray quote in summary",
+			doc:     doc("@PostApi /user", `@Summary 返回 "ok 或错误`, `@Desc 说明 "x`),
+			method:  "POST",
+			path:    "/user",
+			summary: `返回 "ok 或错误`,
+		},
+		{
+			name:    "quoted summary",
+			doc:     doc("@PostApi /user", `@Summary "用户 详情"`),
+			method:  "POST",
+			path:    "/user",
+			summary: "用户 详情",
+		},
+		{
+			name:    "unbalanced bracket in summary",
+			doc:     doc("@GetApi /user", "@Summary 用户详情 (按 id"),
+			method:  "GET",
+			path:    "/user",
+			summary: "用户详情 (按 id",
+		},
+		{
+			name:      "malformed response skips the response only",
+			doc:       doc("@GetApi /user", "@Response 200 Page[User", `@Response 400 "bad`, "@Response 404"),
+			method:    "GET",
+			path:      "/user",
+			responses: 1,
+			docErrs: []string{
+				"a.go:2:18: @Response: unbalanced brackets in type Page[User",
+				"a.go:3:18: unterminated quoted string",
+			},
+		},
+		{
+			name:      "invalid status code",
+			doc:       doc("@GetApi /user", "@Response ok"),
+			method:    "GET",
+			path:      "/user",
+			responses: 0,
+			docErrs:   []string{`a.go:2:14: @Response requires a status code, got "ok"`},
+		},
+		{
+			name:      "duplicate response",
+			doc:       doc("@GetApi /user", "@Response 200", "@Response 200"),
+			method:    "GET",
+			path:      "/user",
+			responses: 1,
+			docErrs:   []string{"a.go:3:4: duplicate @Response 200"},
+		},
+		{
+			name:    "malformed tag",
+			doc:     doc("@GetApi /user", `@Tag "用户`),
+			method:  "GET",
+			path:    "/user",
+			docErrs: []string{"a.go:2:9: unterminated quoted string"},
+		},
+		{
+			name:       "middleware",
+			doc:        doc("@GetApi /user", "@Middleware Auth RateLimit(10, 60)"),
+			method:     "GET",
+			path:       "/user",
+			middleware: []string{"Auth", "RateLimit(10, 60)"},
+		},
+		{
+			name: "unbalanced middleware",
+			doc:  doc("@GetApi /user", "@Middleware RateLimit(10"),
+			err:  `a.go:2:16: @Middleware: invalid middleware "RateLimit(10"`,
+		},
+		{
+			name: "unbalanced params",
+			doc:  doc("@GetApi /user", "@Params Page[User"),
+			err:  "a.go:2:12: @Params: unbalanced brackets in Page[User",
+		},
+		{
+			name: "malformed route",
+			doc:  doc(`@GetApi "/user`),
+			err:  "a.go:1:12: unterminated quoted string",
+		},
+		{
+			name: "missing path",
+			doc:  doc("@GetApi"),
+			err:  "a.go:1:4: @GetApi requires a path",
+		},
+		{
+			name: "duplicate route",
+			doc:  doc("@GetApi /a", "@PostApi /b"),
+			err:  "a.go:2:4: duplicate route annotation @PostApi",
+		},
+		{
+			name: "not api",
+			doc:  doc("@Summary 用户详情", `@Tag "x`),
+			err:  ErrNotApi.Error(),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, err := ParseHandler(tt.doc)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("err = %v, want %s", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if h.Method != tt.method || h.Path != tt.path || h.Summary != tt.summary {
+				t.Errorf("got %s %s %q, want %s %s %q", h.Method, h.Path, h.Summary, tt.method, tt.path, tt.summary)
+			}
+			if !reflect.DeepEqual(h.Middleware, tt.middleware) {
+				t.Errorf("middleware = %q, want %q", h.Middleware, tt.middleware)
+			}
+			if len(h.Responses) != tt.responses {
+				t.Errorf("got %d responses, want %d", len(h.Responses), tt.responses)
+			}
+			var docErrs []string
+			for _, v := range h.Errors {
+				docErrs = append(docErrs, v.Error())
+			}
+			if !reflect.DeepEqual(docErrs, tt.docErrs) {
+				t.Errorf("doc errors = %q, want %q", docErrs, tt.docErrs)
+			}
+		})
+	}
+}
+
+func TestParseResponse(t *testing.T) {
+	h, err := ParseHandler(doc("@GetApi /user", `@Response 200 []Pair[string, int] "用户" "列表"`, `@Response 400 "参数错误"`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Response{
+		{Code: 200, Type: "[]Pair[string, int]", Desc: "用户 列表"},
+		{Code: 400, Desc: "参数错误"},
+	}
+	for i := range h.Responses {
+		h.Responses[i].Pos = token.Position{}
+	}
+	if !reflect.DeepEqual(h.Responses, want) {
+		t.Errorf("responses = %+v, want %+v", h.Responses, want)
+	}
+}
+
+func TestParseController(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  []Line
+		tag  string
+		desc string
+	}{
+		{name: "default tag", doc: doc("User 用户"), tag: "User"},
+		{name: "tag and desc", doc: doc("@Controller 用户 用户管理 (后台"), tag: "用户", desc: "用户管理 (后台"},
+		{name: "quoted tag", doc: doc(`@Controller "用户 管理" "说明"`), tag: "用户 管理", desc: "说明"},
+		{name: "stray quote", doc: doc(`@Controller "用户 管理`), tag: `"用户`, desc: "管理"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := ParseController("User", tt.doc, token.Position{})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if c.Tag != tt.tag || c.Desc != tt.desc {
+				t.Errorf("got %q %q, want %q %q", c.Tag, c.Desc, tt.tag, tt.desc)
+			}
+		})
+	}
+}
+
+func TestParseGeneral(t *testing.T) {
+	g, err := ParseGeneral(doc(
+		`@Title 用户中心 "v2`,
+		"@Version 1.0.0",
+		`@Server https://api.example.com "生产环境" env=prod`,
+		`@Server "http://bad`,
+		"@Contact 张三 email=a@b.com",
+	))
+	if err == nil || err.Error() != "a.go:4:12: unterminated quoted string" {
+		t.Errorf("err = %v, want the malformed @Server", err)
+	}
+	if g.Title != `用户中心 "v2` || g.Version != "1.0.0" {
+		t.Errorf("got title %q version %q", g.Title, g.Version)
+	}
+	if want := []Server{{Url: "https://api.example.com", Desc: "生产环境", Env: "prod"}}; !reflect.DeepEqual(g.Servers, want) {
+		t.Errorf("servers = %+v, want %+v", g.Servers, want)
+	}
+	if g.Contact == nil || g.Contact.Name != "张三" || g.Contact.Email != "a@b.com" {
+		t.Errorf("contact = %+v", g.Contact)
+	}
+}
+
+func TestBalanced(t *testing.T) {
+	tests := map[string]bool{
+		"User":                 true,
+		"Pair[string, int]":    true,
+		"RateLimit(10, 60)":    true,
+		`Limit("(")`:           true,
+		"Page[User":            false,
+		"Page[User)":           false,
+		"a)":                   false,
+		`Limit(")`:             false,
+		"map[string][]Item[T]": true,
+	}
+	for s, want := range tests {
+		if got := balanced(s); got != want {
+			t.Errorf("balanced(%q) = %v, want %v", s, got, want)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package annotation
+
+import (
+	"go/scanner"
+	"strings"
+)
+
+// General main 函数上描述整个接口文档的注解, 如
+//
+//	// @Title 用户中心
+//	// @Version 1.0.0
+//	// @Host api.example.com
+//	// @BasePath /api
+//	// @Server https://api.example.com "生产环境" env=prod
+type General struct {
+	Title          string
+	Version        string
+	Desc           string
+	TermsOfService string
+	Host           string
+	BasePath       string
+	Schemes        []string
+	// Contact @Contact name [url=] [email=]
+	Contact *Contact
+	// License @License name [url=]
+	License     *License
+	Servers     []Server
+	Annotations []Annotation
+}
+
+type Contact struct {
+	Name  string
+	Url   string
+	Email string
+}
+
+type License struct {
+	Name string
+	Url  string
+}
+
+// Server @Server url ["description"] [env=], env 为空时适用于所有环境
+type Server struct {
+	Url  string
+	Desc string
+	Env  string
+}
+
+// ParseGeneral 解析 main 函数的文档注释, 其他注解与普通注释会被忽略, 参数有误的注解被跳过
+func ParseGeneral(doc []Line) (*General, error) {
+	list, _ := Parse(doc)
+	var errs scanner.ErrorList
+	g := &General{Annotations: list}
+	for _, a := range list {
+		switch a.Name {
+		case "Schemes", "Contact", "License", "Server":
+			if a.Err != nil {
+				errs = append(errs, a.Err)
+				continue
+			}
+		}
+		switch a.Name {
+		case "Title":
+			g.Title = text(a)
+		case "Version":
+			g.Version = text(a)
+		case "Desc", "Description":
+			g.Desc = text(a)
+		case "TermsOfService":
+			g.TermsOfService = text(a)
+		case "Host":
+			g.Host = text(a)
+		case "BasePath":
+			g.BasePath = text(a)
+		case "Schemes":
+			g.Schemes = a.Positional()
+		case "Contact":
+			g.Contact = &Contact{Name: strings.Join(a.Positional(), " ")}
+			g.Contact.Url, _ = a.Option("url")
+			g.Contact.Email, _ = a.Option("email")
+		case "License":
+			g.License = &License{Name: strings.Join(a.Positional(), " ")}
+			g.License.Url, _ = a.Option("url")
+		case "Server":
+			args := a.Positional()
+			if len(args) == 0 {
+				errs.Add(a.Pos, "@Server requires a url")
+				continue
+			}
+			s := Server{Url: args[0], Desc: strings.Join(args[1:], " ")}
+			s.Env, _ = a.Option("env")
+			g.Servers = append(g.Servers, s)
+		}
+	}
+	errs.Sort()
+	return g, errs.Err()
+}
@@ -0,0 +1,286 @@
+package annotation
+
+import (
+	"errors"
+	"go/scanner"
+	"go/token"
+	"strconv"
+	"strings"
+)
+
+// ErrNotApi 处理函数没有路由注解
+var ErrNotApi = errors.New("not api")
+
+// routes 路由注解 => HTTP 方法
+var routes = map[string]string{
+	"AnyApi":    "ANY",
+	"GetApi":    "GET",
+	"PostApi":   "POST",
+	"PutApi":    "PUT",
+	"DeleteApi": "DELETE",
+}
+
+// Handler 处理函数上的注解
+type Handler struct {
+	Method     string
+	Path       string
+	Summary    string
+	Desc       string
+	Tags       []string
+	Params     string
+	Middleware []string
+	Responses  []Response
+	Security   []Security
+	// OperationId @OperationId 指定的 operationId, 为空时由控制器与方法名生成
+	OperationId string
+	// Pos 路由注解的位置
+	Pos         token.Position
+	Annotations []Annotation
+	// Errors 只影响文档的注解中的错误, 如 @Response, @Tag, 出错的注解被跳过, 不影响路由
+	Errors scanner.ErrorList
+}
+
+// Find 返回指定名称的所有注解
+func (h *Handler) Find(name string) (result []Annotation) {
+	for _, v := range h.Annotations {
+		if v.Name == name {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+// Response @Response 注解, 语法为 @Response code [Type] ["description"], 如 @Response 200 UserList "用户列表"
+type Response struct {
+	Code int
+	// Type 响应数据的类型表达式, 可为空
+	Type string
+	Desc string
+	Pos  token.Position
+}
+
+// Security @Security 注解, 语法为 @Security name [scope...], 如 @Security oauth user:read.
+// 多条 @Security 满足其一即可
+type Security struct {
+	Name   string
+	Scopes []string
+	Pos    token.Position
+}
+
+// Controller 控制器结构体上的注解
+type Controller struct {
+	Tag  string
+	Desc string
+	// Security 控制器下所有处理函数默认的安全要求
+	Security    []Security
+	Pos         token.Position
+	Annotations []Annotation
+}
+
+// ParseHandler 解析处理函数的文档注释, 没有路由注解时返回 ErrNotApi.
+// 返回的错误只来自决定路由的注解(路由, @Params, @Middleware), 其他注解的错误记录在 Handler.Errors 中
+func ParseHandler(doc []Line) (*Handler, error) {
+	list, _ := Parse(doc)
+	var errs scanner.ErrorList
+	h := &Handler{Annotations: list}
+	for _, a := range list {
+		if method, ok := routes[a.Name]; ok {
+			if a.Err != nil {
+				errs = append(errs, a.Err)
+				continue
+			}
+			args := a.Positional()
+			if h.Method != "" {
+				errs.Add(a.Pos, "duplicate route annotation @"+a.Name)
+				continue
+			}
+			if len(args) == 0 {
+				errs.Add(a.Pos, "@"+a.Name+" requires a path")
+				continue
+			}
+			h.Method, h.Path, h.Pos = method, args[0], a.Pos
+			continue
+		}
+		switch a.Name {
+		case "Summary":
+			h.Summary = text(a)
+		case "Desc":
+			h.Desc = text(a)
+		case "Tag":
+			if a.Err != nil {
+				h.Errors = append(h.Errors, a.Err)
+				continue
+			}
+			h.Tags = a.Positional()
+		case "Params":
+			if a.Err != nil {
+				errs = append(errs, a.Err)
+				continue
+			}
+			args := a.Positional()
+			if len(args) == 0 {
+				errs.Add(a.Pos, "@Params requires a struct name")
+				continue
+			}
+			if !balanced(args[0]) {
+				errs.Add(a.Args[0].Pos, "@Params: unbalanced brackets in "+args[0])
+				continue
+			}
+			h.Params = args[0]
+		case "OperationId":
+			args := a.Positional()
+			if a.Err == nil && len(args) != 1 {
+				a.Err = &scanner.Error{Pos: a.Pos, Msg: "@OperationId requires exactly one name"}
+			}
+			if a.Err != nil {
+				h.Errors = append(h.Errors, a.Err)
+				continue
+			}
+			h.OperationId = args[0]
+		case "Middleware":
+			if a.Err != nil {
+				errs = append(errs, a.Err)
+				continue
+			}
+			for _, v := range a.Args {
+				if v.Key != "" || !balanced(v.Value) {
+					errs.Add(v.Pos, "@Middleware: invalid middleware "+strconv.Quote(v.Value))
+					continue
+				}
+				h.Middleware = append(h.Middleware, v.Value)
+			}
+		case "Response":
+			// 不带参数的 @Response 为控制器模板中的占位
+			if a.Err == nil && len(a.Args) == 0 {
+				continue
+			}
+			r, err := parseResponse(a)
+			if err != nil {
+				h.Errors = append(h.Errors, err.(*scanner.Error))
+				continue
+			}
+			duplicate := false
+			for _, v := range h.Responses {
+				duplicate = duplicate || v.Code == r.Code
+			}
+			if duplicate {
+				h.Errors.Add(a.Pos, "duplicate @Response "+strconv.Itoa(r.Code))
+				continue
+			}
+			h.Responses = append(h.Responses, r)
+		case "Security":
+			sec, err := parseSecurity(a)
+			if err != nil {
+				h.Errors = append(h.Errors, err.(*scanner.Error))
+				continue
+			}
+			h.Security = append(h.Security, sec)
+		}
+	}
+	h.Errors.Sort()
+	if h.Method == "" && len(errs) == 0 {
+		return nil, ErrNotApi
+	}
+	errs.Sort()
+	return h, errs.Err()
+}
+
+func parseResponse(a Annotation) (r Response, err error) {
+	r.Pos = a.Pos
+	if a.Err != nil {
+		return r, a.Err
+	}
+	code, err := strconv.Atoi(a.Args[0].Value)
+	if err != nil || code < 100 || code > 599 {
+		return r, &scanner.Error{Pos: a.Args[0].Pos, Msg: "@Response requires a status code, got " + strconv.Quote(a.Args[0].Value)}
+	}
+	r.Code = code
+	var desc []string
+	for i, v := range a.Args[1:] {
+		if v.Key != "" {
+			return r, &scanner.Error{Pos: v.Pos, Msg: "unknown @Response option " + v.Key}
+		}
+		if i == 0 && !v.Quoted {
+			if !balanced(v.Value) {
+				return r, &scanner.Error{Pos: v.Pos, Msg: "@Response: unbalanced brackets in type " + v.Value}
+			}
+			r.Type = v.Value
+			continue
+		}
+		desc = append(desc, v.Value)
+	}
+	r.Desc = strings.Join(desc, " ")
+	return r, nil
+}
+
+func parseSecurity(a Annotation) (s Security, err error) {
+	s.Pos = a.Pos
+	if a.Err != nil {
+		return s, a.Err
+	}
+	for _, v := range a.Args {
+		if v.Key != "" {
+			return s, &scanner.Error{Pos: v.Pos, Msg: "unknown @Security option " + v.Key}
+		}
+	}
+	args := a.Positional()
+	if len(args) == 0 {
+		return s, &scanner.Error{Pos: a.Pos, Msg: "@Security requires a scheme name"}
+	}
+	s.Name, s.Scopes = args[0], args[1:]
+	return s, nil
+}
+
+// ParseController 解析控制器结构体的文档注释, Tag 默认为结构体名.
+// @Controller 的第一个词为 Tag, 可加引号以包含空白, 其余文本为描述
+func ParseController(name string, doc []Line, pos token.Position) (*Controller, error) {
+	list, _ := Parse(doc)
+	var errs scanner.ErrorList
+	c := &Controller{Tag: name, Pos: pos, Annotations: list}
+	for _, a := range list {
+		if a.Name == "Security" {
+			sec, err := parseSecurity(a)
+			if err != nil {
+				errs = append(errs, err.(*scanner.Error))
+				continue
+			}
+			c.Security = append(c.Security, sec)
+			continue
+		}
+		if a.Name != "Controller" {
+			continue
+		}
+		tag, desc := firstWord(a.Text)
+		if tag != "" {
+			c.Tag = tag
+		}
+		c.Desc = text(Annotation{Text: desc})
+		c.Pos = a.Pos
+	}
+	errs.Sort()
+	return c, errs.Err()
+}
+
+// firstWord 拆分出文本的第一个词, 以引号开头且引号完整时取引号内的值
+func firstWord(s string) (word string, rest string) {
+	if strings.HasPrefix(s, `"`) {
+		if q, err := strconv.QuotedPrefix(s); err == nil {
+			word, _ = strconv.Unquote(q)
+			return word, strings.TrimSpace(s[len(q):])
+		}
+	}
+	if i := strings.IndexAny(s, " \t"); i > 0 {
+		return s[:i], strings.TrimSpace(s[i:])
+	}
+	return s, ""
+}
+
+// text 自由文本注解的内容, 整体加引号时取引号内的值
+func text(a Annotation) string {
+	if strings.HasPrefix(a.Text, `"`) {
+		if v, err := strconv.Unquote(a.Text); err == nil {
+			return v
+		}
+	}
+	return a.Text
+}
@@ -0,0 +1,179 @@
+// Package diag 收集整个运行过程中的诊断信息, 最后统一输出
+package diag
+
+import (
+	"fmt"
+	"go/scanner"
+	"go/token"
+	"io"
+	"sort"
+	"sync"
+)
+
+type Severity int
+
+const (
+	Warning Severity = iota
+	Error
+)
+
+func (s Severity) String() string {
+	if s == Error {
+		return "error"
+	}
+	return "warning"
+}
+
+type Code string
+
+const (
+	// ParseError 源码语法或类型检查错误
+	ParseError Code = "parse-error"
+	// MalformedAnnotation 注解格式错误
+	MalformedAnnotation Code = "malformed-annotation"
+	// UnknownParams @Params 等注解引用的结构体不存在
+	UnknownParams Code = "unknown-params"
+	// DuplicateRoute 相同的 method + path 被多次声明
+	DuplicateRoute Code = "duplicate-route"
+	// DuplicateOperationId 多个接口使用了相同的 operationId
+	DuplicateOperationId Code = "duplicate-operation-id"
+	// UnsupportedParamType 无法映射的参数类型
+	UnsupportedParamType Code = "unsupported-param-type"
+	// UnknownSecurity @Security 引用的安全方案未在配置中定义
+	UnknownSecurity Code = "unknown-security"
+	// GenerateError 生成或写入文件失败
+	GenerateError Code = "generate-error"
+)
+
+type Diagnostic struct {
+	Pos      token.Position
+	Severity Severity
+	Code     Code
+	Msg      string
+}
+
+func (d Diagnostic) String() string {
+	if d.Pos.IsValid() || d.Pos.Filename != "" {
+		return fmt.Sprintf("%s: %s[%s]: %s", d.Pos, d.Severity, d.Code, d.Msg)
+	}
+	return fmt.Sprintf("%s[%s]: %s", d.Severity, d.Code, d.Msg)
+}
+
+type Collector struct {
+	mu   sync.Mutex
+	list []Diagnostic
+	seen map[Diagnostic]bool
+}
+
+// Add 记录一条诊断, 多个生成器解析同一处注解时相同的诊断只保留一条
+func (c *Collector) Add(d Diagnostic) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.seen[d] {
+		return
+	}
+	if c.seen == nil {
+		c.seen = make(map[Diagnostic]bool)
+	}
+	c.seen[d] = true
+	c.list = append(c.list, d)
+}
+
+func (c *Collector) Errorf(pos token.Position, code Code, format string, args ...interface{}) {
+	c.Add(Diagnostic{Pos: pos, Severity: Error, Code: code, Msg: fmt.Sprintf(format, args...)})
+}
+
+func (c *Collector) Warnf(pos token.Position, code Code, format string, args ...interface{}) {
+	c.Add(Diagnostic{Pos: pos, Severity: Warning, Code: code, Msg: fmt.Sprintf(format, args...)})
+}
+
+// Report 记录 err, scanner.ErrorList 中的每一项会作为单独的诊断, 并保留位置信息
+func (c *Collector) Report(severity Severity, code Code, err error) {
+	switch e := err.(type) {
+	case nil:
+		return
+	case scanner.ErrorList:
+		for _, v := range e {
+			c.Add(Diagnostic{Pos: v.Pos, Severity: severity, Code: code, Msg: v.Msg})
+		}
+	case *scanner.Error:
+		c.Add(Diagnostic{Pos: e.Pos, Severity: severity, Code: code, Msg: e.Msg})
+	default:
+		c.Add(Diagnostic{Severity: severity, Code: code, Msg: err.Error()})
+	}
+}
+
+// List 按位置排序后的诊断列表
+func (c *Collector) List() []Diagnostic {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	list := append([]Diagnostic{}, c.list...)
+	sort.SliceStable(list, func(i, j int) bool {
+		a, b := list[i].Pos, list[j].Pos
+		if a.Filename != b.Filename {
+			return a.Filename < b.Filename
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+	return list
+}
+
+func (c *Collector) HasErrors() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, d := range c.list {
+		if d.Severity == Error {
+			return true
+		}
+	}
+	return false
+}
+
+// Print 输出全部诊断及汇总
+func (c *Collector) Print(w io.Writer) {
+	list := c.List()
+	errs := 0
+	for _, d := range list {
+		if d.Severity == Error {
+			errs++
+		}
+		fmt.Fprintln(w, d)
+	}
+	if len(list) > 0 {
+		fmt.Fprintf(w, "%d error(s), %d warning(s)\n", errs, len(list)-errs)
+	}
+}
+
+// Reset 清空已收集的诊断
+func (c *Collector) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.list = nil
+	c.seen = nil
+}
+
+// Default 全局收集器, 各生成器共用
+var Default = &Collector{}
+
+func Errorf(pos token.Position, code Code, format string, args ...interface{}) {
+	Default.Errorf(pos, code, format, args...)
+}
+
+func Warnf(pos token.Position, code Code, format string, args ...interface{}) {
+	Default.Warnf(pos, code, format, args...)
+}
+
+func Report(severity Severity, code Code, err error) {
+	Default.Report(severity, code, err)
+}
+
+func HasErrors() bool {
+	return Default.HasErrors()
+}
+
+func Print(w io.Writer) {
+	Default.Print(w)
+}
@@ -1,11 +1,10 @@
 package gen
 
 import (
-	"bufio"
 	"bytes"
 	"fmt"
 	"go/format"
-	"io"
+	"go/token"
 	"io/ioutil"
 	"os"
 	"regexp"
@@ -18,6 +17,8 @@ import (
 	"github.com/daodao97/egin/db"
 	"github.com/daodao97/egin/lib"
 
+	"github.com/daodao97/egin-tools/annotation"
+	"github.com/daodao97/egin-tools/diag"
 	"github.com/daodao97/egin-tools/parser"
 )
 
@@ -43,38 +44,53 @@ func Gen(data map[string]interface{}, tpl string) (string, error) {
 	return string(byteData), nil
 }
 
-func apiMethod(doc []string) (method string, path string, err error) {
-	if doc == nil {
-		return "", "", errors.New("not api")
-	}
-	reg := regexp.MustCompile("^@(Any|Get|Put|Post|Delete)Api\\s?([a-zA-Z0-9_/:*]+)?\\s?.*")
-	matched := reg.FindStringSubmatch(doc[0])
-	if len(matched) != 3 {
-		return "", "", errors.New("not api")
-	}
-	return strings.ToUpper(matched[1]), matched[2], nil
-}
+var errDuplicateRoute = errors.New("duplicate route")
 
-func apiMiddleware(doc []string) []string {
-	reg := regexp.MustCompile(`@Middleware .*`)
-	for _, v := range doc {
-		if matched := reg.MatchString(v); matched {
-			return strings.Split(v, " ")[1:]
-		}
-	}
-	return []string{}
+var errUnsupportedParam = errors.New("unsupported param type")
+
+// routeFileImports 路由文件模板中固定导入的包, 包名 => 导入路径, controller 包的路径由模块名决定
+var routeFileImports = map[string]string{
+	"strconv":    "strconv",
+	"strings":    "strings",
+	"egin":       "github.com/daodao97/egin",
+	"consts":     "github.com/daodao97/egin/consts",
+	"middleware": "github.com/daodao97/egin/middleware",
+	"utils":      "github.com/daodao97/egin/utils",
+	"gin":        "github.com/gin-gonic/gin",
 }
 
-func MakeRouteHandle(entity string, info parser.StructFunc) (code string, err error) {
-	method, path, err := apiMethod(info.Doc)
+// declaredRoutes 已生成的路由 => 声明位置, 用于检查重复路由
+var declaredRoutes = make(map[string]token.Position)
+
+// MakeRouteHandle 生成一个处理函数的路由注册代码, ctrlPath 为控制器包的导入路径,
+// 参数类型引用的其他包记录到 imports (包名 => 导入路径)
+func MakeRouteHandle(entity string, info parser.StructFunc, prog *parser.Program, ctrlPath string, imports map[string]string) (code string, err error) {
+	handler, err := annotation.ParseHandler(info.Doc)
 	if err != nil {
-		return "", errors.New("not api")
+		return "", err
 	}
+	// 只影响文档的注解错误不妨碍生成路由
+	diag.Report(diag.Error, diag.MalformedAnnotation, handler.Errors.Err())
+	method, path := handler.Method, handler.Path
+
+	// 路径参数写作 {name}, 与文档中的路径一致, 两者报告的重复路由可以合并为一条
+	route := method + " " + routeKey(path)
+	if pos, ok := declaredRoutes[route]; ok {
+		diag.Errorf(handler.Pos, diag.DuplicateRoute, "duplicate route %s, first declared at %s", route, pos)
+		return "", errDuplicateRoute
+	}
+	declaredRoutes[route] = handler.Pos
 
 	res := regexp.MustCompile(":([a-zA-Z0-9*])+")
 	pathArgs := res.FindAllString(path, -1)
 	for i, v := range pathArgs {
 		pathArgs[i] = strings.TrimPrefix(v, ":")
+		// 路径参数按顺序对应处理函数 ctx 之后的参数, 只能是 int 或 string
+		if i+1 < len(info.Params) {
+			if t := info.Params[i+1].Type.String(); t != "int" && t != "string" {
+				diag.Errorf(info.Pos, diag.UnsupportedParamType, "%s: path param %s has unsupported type %s", info.Name, pathArgs[i], t)
+			}
+		}
 	}
 
 	method = strings.ToUpper(method)
@@ -84,16 +100,15 @@ func MakeRouteHandle(entity string, info parser.StructFunc) (code string, err er
 	}
 
 	args := map[string]interface{}{
-		"method":     method,
-		"entity":     entity,
-		"path":       path,
-		"funcName":   info.Name,
-		"moduleName": ModuleName(),
-		"pathArgs":   pathArgs,
+		"method":   method,
+		"entity":   entity,
+		"path":     path,
+		"funcName": info.Name,
+		"pathArgs": pathArgs,
 	}
 
 	args["middleware"] = ""
-	middleware := apiMiddleware(info.Doc)
+	middleware := append([]string{}, handler.Middleware...)
 	if len(middleware) > 0 {
 		for i, v := range middleware {
 			if !strings.HasSuffix(v, ")") {
@@ -106,37 +121,199 @@ func MakeRouteHandle(entity string, info parser.StructFunc) (code string, err er
 	}
 
 	tpl := SimpleApi
-	if len(info.Params) == 3 {
-		args["paramsStruct"] = info.Params[len(info.Params)-1].Type
+	if len(info.Params) == 3 || len(info.Params) == 2 && info.Params[1].Type.String() != "int" {
 		tpl = ApiWithParam
 	}
-	if len(info.Params) == 2 {
-		if info.Params[1].Type == "int" {
-			tpl = SimpleApi
-		} else {
-			args["paramsStruct"] = info.Params[1].Type
-			tpl = ApiWithParam
-		}
-	}
 	if info.ResultCount == 0 {
 		tpl = ApiReturnVoid
 	}
+	// 参数类型所需的导入先记在副本中, 生成成功后再合并, 跳过的处理函数不影响路由文件的导入
+	used := make(map[string]string, len(imports))
+	for k, v := range imports {
+		used[k] = v
+	}
+	if tpl == ApiWithParam {
+		t := info.Params[len(info.Params)-1].Type
+		paramsStruct, err := qualify(t, ctrlPath, used)
+		if err != nil {
+			diag.Errorf(info.Pos, diag.UnsupportedParamType, "%s: param type %s cannot be used in routes: %s", info.Name, t, err)
+			return "", errUnsupportedParam
+		}
+		args["paramsStruct"] = paramsStruct
+	}
+	args["enumChecks"] = ""
+	if tpl == ApiWithParam {
+		if si, ok := prog.Lookup(info.Params[len(info.Params)-1].Type); ok {
+			args["enumChecks"] = enumChecks(si, prog)
+		}
+	}
 
-	return Gen(args, tpl)
+	code, err = Gen(args, tpl)
+	if err != nil {
+		return "", err
+	}
+	for k, v := range used {
+		imports[k] = v
+	}
+	return code, nil
+}
+
+// routeKey 将 :name 与 *name 形式的路径参数写作 {name}
+func routeKey(path string) string {
+	segments := strings.Split(path, "/")
+	for i, v := range segments {
+		if strings.HasPrefix(v, ":") || strings.HasPrefix(v, "*") {
+			segments[i] = "{" + v[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/")
 }
 
-func MakeRouteFile(structInfo []parser.StructInfo, varsInfo []parser.VarInfo) {
+// qualify 参数类型在路由文件中的写法, 控制器包内的类型(含泛型实参)加上 controller. 前缀,
+// 其他包的类型沿用源码中的包名, 并记录到 imports 中
+func qualify(t *parser.Type, ctrlPath string, imports map[string]string) (string, error) {
+	if t == nil {
+		return "", nil
+	}
+	switch t.Kind {
+	case parser.KindBasic:
+		return t.Name, nil
+	case parser.KindPointer, parser.KindSlice, parser.KindArray:
+		elem, err := qualify(t.Elem, ctrlPath, imports)
+		if err != nil {
+			return "", err
+		}
+		switch t.Kind {
+		case parser.KindPointer:
+			return "*" + elem, nil
+		case parser.KindSlice:
+			return "[]" + elem, nil
+		}
+		return "[" + t.Len + "]" + elem, nil
+	case parser.KindMap:
+		key, err := qualify(t.Key, ctrlPath, imports)
+		if err != nil {
+			return "", err
+		}
+		elem, err := qualify(t.Elem, ctrlPath, imports)
+		if err != nil {
+			return "", err
+		}
+		return "map[" + key + "]" + elem, nil
+	case parser.KindNamed:
+		name := t.Name
+		switch {
+		case t.PkgPath == "":
+			// 预声明的类型, 如 error
+		case t.PkgPath == ctrlPath:
+			name = "controller." + t.Name
+		case t.Pkg == "":
+			return "", errors.Errorf("type %s from %s has no package name in source", t.Name, t.PkgPath)
+		default:
+			if path, ok := imports[t.Pkg]; ok && path != t.PkgPath {
+				return "", errors.Errorf("package name %s of %s conflicts with import %s", t.Pkg, t.PkgPath, path)
+			}
+			imports[t.Pkg] = t.PkgPath
+			name = t.Pkg + "." + t.Name
+		}
+		if len(t.Args) > 0 {
+			args := make([]string, len(t.Args))
+			for i, a := range t.Args {
+				arg, err := qualify(a, ctrlPath, imports)
+				if err != nil {
+					return "", err
+				}
+				args[i] = arg
+			}
+			name += "[" + strings.Join(args, ", ") + "]"
+		}
+		return name, nil
+	}
+	return "", errors.Errorf("%s type is not supported", t.Kind)
+}
+
+// enumChecks 参数结构体中枚举类型字段的取值校验, 非必填字段允许零值
+func enumChecks(si parser.StructInfo, prog *parser.Program) string {
+	var buf strings.Builder
+	for _, f := range si.Fields {
+		if f.Embedded || !token.IsExported(f.Name) {
+			continue
+		}
+		consts := prog.Enum(f.Type)
+		named, ok := prog.NamedType(f.Type)
+		if len(consts) == 0 || !ok || named.Type.Kind != parser.KindBasic {
+			continue
+		}
+		var zero string
+		switch named.Type.Name {
+		case "string":
+			zero = `""`
+		case "int", "int8", "int16", "int32", "int64", "uint", "uint8", "uint16", "uint32", "uint64", "byte", "rune":
+			zero = "0"
+		default:
+			continue
+		}
+		var values []string
+		seen := make(map[string]bool)
+		for _, c := range consts {
+			if c.Value != "" && !seen[c.Value] {
+				seen[c.Value] = true
+				values = append(values, c.Value)
+			}
+		}
+		msg := fmt.Sprintf("%s must be one of %s", paramName(f), strings.Join(values, ", "))
+		rules := strings.Split(f.Tags["binding"], ",")
+		if _, required := lib.Find(rules, "required"); !required && !seen[zero] {
+			values = append([]string{zero}, values...)
+		}
+		fmt.Fprintf(&buf, "switch params.%s {\ncase %s:\ndefault:\n\tegin.Fail(ctx, consts.ErrorParam, %q)\n\treturn\n}\n",
+			f.Name, strings.Join(values, ", "), msg)
+	}
+	return buf.String()
+}
+
+// paramName 字段在请求中的名称, 优先取 json 标签
+func paramName(f parser.StructField) string {
+	if name := strings.Split(f.Tags["json"], ",")[0]; name != "" && name != "-" {
+		return name
+	}
+	return f.Name
+}
+
+func MakeRouteFile(structInfo []parser.StructInfo, varsInfo []parser.VarInfo, prog *parser.Program) {
+	// 没有模块名时生成的导入路径无效, 不覆盖已有的路由文件
+	moduleName, err := ModuleName()
+	if err != nil {
+		diag.Errorf(token.Position{}, diag.GenerateError, "gen config/routes: %s", err)
+		return
+	}
 	for _, v := range structInfo {
 		entity := v.Name
 		var handles []string
+		imports := map[string]string{"controller": moduleName + "/controller"}
+		for k, path := range routeFileImports {
+			imports[k] = path
+		}
+		// 有处理函数无法生成时保留原有的路由文件, 避免缺少的路由被悄悄删除
+		failed := false
 		for _, f := range v.Funcs {
-			handle, err := MakeRouteHandle(v.Name, f)
+			handle, err := MakeRouteHandle(v.Name, f, prog, v.PkgPath, imports)
+			if err == annotation.ErrNotApi || err == errDuplicateRoute {
+				continue
+			}
 			if err != nil {
-				fmt.Println(err)
+				if err != errUnsupportedParam {
+					diag.Report(diag.Error, diag.MalformedAnnotation, err)
+				}
+				failed = true
 				continue
 			}
 			handles = append(handles, handle)
 		}
+		if failed {
+			diag.Warnf(v.Pos, diag.GenerateError, "config/routes/%s.go is not updated, fix the errors of %s first", lib.ToSnakeCase(entity), entity)
+			continue
+		}
 		if len(handles) == 0 {
 			continue
 		}
@@ -146,8 +323,16 @@ func MakeRouteFile(structInfo []parser.StructInfo, varsInfo []parser.VarInfo) {
 			"entity":                 entity,
 			"handles":                handles,
 			"hasCustomValidateFuncs": false,
-			"moduleName":             ModuleName(),
+			"moduleName":             moduleName,
+		}
+		// 参数类型引用的其他包, 固定导入的包已写在模板中
+		extra := map[string]string{}
+		for k, path := range imports {
+			if _, ok := routeFileImports[k]; !ok && k != "controller" {
+				extra[k] = path
+			}
 		}
+		argsR["imports"] = extra
 
 		var customValidateVarsName []string
 		for _, v := range varsInfo {
@@ -160,20 +345,26 @@ func MakeRouteFile(structInfo []parser.StructInfo, varsInfo []parser.VarInfo) {
 
 		tpl, err := Gen(argsR, RouteFile)
 		if err != nil {
-			fmt.Println("gen config/routes/***.go error", err)
-			os.Exit(1)
+			diag.Errorf(v.Pos, diag.GenerateError, "gen config/routes/%s.go error: %s", lib.ToSnakeCase(entity), err)
+			continue
 		}
-		_ = ioutil.WriteFile(fmt.Sprintf("config/routes/%s.go", lib.ToSnakeCase(entity)), []byte(tpl), os.FileMode(0644))
+		writeFile(fmt.Sprintf("config/routes/%s.go", lib.ToSnakeCase(entity)), tpl)
 	}
 }
 
 func MakeRouteExport() {
+	moduleName, err := ModuleName()
+	if err != nil {
+		diag.Errorf(token.Position{}, diag.GenerateError, "gen config/routes.go: %s", err)
+		return
+	}
 	var funcs []parser.FuncInfo
 	lib.RecursiveDir("config/routes", func(filePath string) {
 		fmt.Println(filePath)
 		funcInfo, err := parser.FileFunInfo(filePath)
 		if err != nil {
-			panic(err)
+			diag.Report(diag.Error, diag.ParseError, err)
+			return
 		}
 		funcs = append(funcs, funcInfo...)
 	})
@@ -189,14 +380,14 @@ func MakeRouteExport() {
 	}
 	args := map[string]interface{}{
 		"list":       sort.StringSlice(list),
-		"moduleName": ModuleName(),
+		"moduleName": moduleName,
 	}
 	tpl, err := Gen(args, RouteExport)
 	if err != nil {
-		fmt.Println("gen config/route.go error", err)
-		os.Exit(1)
+		diag.Errorf(token.Position{}, diag.GenerateError, "gen config/routes.go error: %s", err)
+		return
 	}
-	_ = ioutil.WriteFile("config/routes.go", []byte(tpl), os.FileMode(0644))
+	writeFile("config/routes.go", tpl)
 }
 
 type TableField struct {
@@ -220,12 +411,12 @@ func getType(str string) string {
 func MakeModel(connection string, databases string, table TableInfo) {
 	mysqlDb, ok := db.GetDBInPool(connection)
 	if !ok {
-		fmt.Println("get pool error")
+		diag.Errorf(token.Position{}, diag.GenerateError, "get pool %s error", connection)
 		return
 	}
 	rows, err := mysqlDb.Query("select `COLUMN_NAME`, `DATA_TYPE`, `COLUMN_COMMENT` from information_schema.COLUMNS where `TABLE_SCHEMA` = ? and `TABLE_NAME` = ? order by ORDINAL_POSITION", databases, table.Name)
 	if err != nil {
-		fmt.Println("table schema fail ", err)
+		diag.Errorf(token.Position{}, diag.GenerateError, "table schema fail: %s", err)
 		return
 	}
 
@@ -263,15 +454,14 @@ func MakeModel(connection string, databases string, table TableInfo) {
 		"backquote":    "`",
 		"fakeDel":      fakeDel,
 		"tableComment": table.Comment,
-		"moduleName":   ModuleName(),
 	}
 
 	tpl, err := Gen(args, Entity)
 	if err != nil {
-		fmt.Println("gen model/***.go error", err)
-		os.Exit(1)
+		diag.Errorf(token.Position{}, diag.GenerateError, "gen model/%s.go error: %s", table.Name, err)
+		return
 	}
-	_ = ioutil.WriteFile(fmt.Sprintf("model/%s.go", table.Name), []byte(tpl), os.FileMode(0644))
+	writeFile(fmt.Sprintf("model/%s.go", table.Name), tpl)
 }
 
 type TableInfo struct {
@@ -282,12 +472,12 @@ type TableInfo struct {
 func GetDbAllTable(connection string, databases string) (result []TableInfo) {
 	mysqlDb, ok := db.GetDBInPool(connection)
 	if !ok {
-		fmt.Println("get pool error")
+		diag.Errorf(token.Position{}, diag.GenerateError, "get pool %s error", connection)
 		return
 	}
 	rows, err := mysqlDb.Query("select table_name,table_comment from information_schema.tables where table_schema=? and table_type='base table';", databases)
 	if err != nil {
-		fmt.Println("table schema fail ", err)
+		diag.Errorf(token.Position{}, diag.GenerateError, "table schema fail: %s", err)
 		return
 	}
 
@@ -309,12 +499,12 @@ func GetDbAllTable(connection string, databases string) (result []TableInfo) {
 func GetTableInfo(connection string, databases string, table string) (result TableInfo) {
 	mysqlDb, ok := db.GetDBInPool(connection)
 	if !ok {
-		fmt.Println("get pool error")
+		diag.Errorf(token.Position{}, diag.GenerateError, "get pool %s error", connection)
 		return
 	}
 	rows, err := mysqlDb.Query("select table_name,table_comment from information_schema.tables where table_schema=? and table_type='base table' and table_name = ?;", databases, table)
 	if err != nil {
-		fmt.Println("table schema fail ", err)
+		diag.Errorf(token.Position{}, diag.GenerateError, "table schema fail: %s", err)
 		return
 	}
 
@@ -331,21 +521,18 @@ func GetTableInfo(connection string, databases string, table string) (result Tab
 	return result
 }
 
-func ModuleName() string {
-	fi, err := os.Open("go.mod")
+// ModuleName 读取 go.mod 中声明的模块名
+func ModuleName() (string, error) {
+	content, err := ioutil.ReadFile("go.mod")
 	if err != nil {
-		fmt.Printf("Error: %s\n", err)
-		os.Exit(1)
+		return "", errors.Wrap(err, "read module name")
 	}
-	defer fi.Close()
-
-	br := bufio.NewReader(fi)
-	a, _, c := br.ReadLine()
-	if c == io.EOF {
-		return ""
+	for _, line := range strings.Split(string(content), "\n") {
+		if fields := strings.Fields(line); len(fields) == 2 && fields[0] == "module" {
+			return strings.Trim(fields[1], `"`), nil
+		}
 	}
-
-	return strings.Replace(string(a), "module ", "", 1)
+	return "", errors.New("read module name: no module directive in go.mod")
 }
 
 func MakeController(tableName string, desc string) {
@@ -357,9 +544,15 @@ func MakeController(tableName string, desc string) {
 	}
 	tpl, err := Gen(args, ctrlTpl)
 	if err != nil {
-		fmt.Println("gen controller/***.go error", err)
-		os.Exit(1)
+		diag.Errorf(token.Position{}, diag.GenerateError, "gen controller/%s.go error: %s", tableName, err)
+		return
 	}
-	_ = ioutil.WriteFile(fmt.Sprintf("controller/%s.go", tableName), []byte(tpl), os.FileMode(0644))
+	writeFile(fmt.Sprintf("controller/%s.go", tableName), tpl)
+
+}
 
+func writeFile(name string, content string) {
+	if err := ioutil.WriteFile(name, []byte(content), os.FileMode(0644)); err != nil {
+		diag.Errorf(token.Position{}, diag.GenerateError, "write %s: %s", name, err)
+	}
 }
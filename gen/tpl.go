@@ -17,12 +17,13 @@ r.{{ .method }}("{{ .path }}", func(ctx *gin.Context) {
 const ApiWithParam = `
 r.{{ .method }}("{{ .path }}", func () func(ctx *gin.Context) {
 	return func(ctx *gin.Context) {
-		var params controller.{{ .paramsStruct }}
+		var params {{ .paramsStruct }}
 		errs := utils.Validated(ctx, &params)
 		if errs != nil {
 			egin.Fail(ctx, consts.ErrorParam, strings.Join(errs, "\n"))
 			return
 		}
+		{{ .enumChecks }}
 		{{- range $index, $value := .pathArgs }} 
 			{{ if eq $value "id"}}
 			{{$value}}, _ := strconv.Atoi(ctx.Param("{{$value}}"))
@@ -59,6 +60,9 @@ import (
 	"github.com/gin-gonic/gin"
 
 	"{{ .moduleName }}/controller"
+	{{- range $name, $path := .imports }}
+	{{ $name }} "{{ $path }}"
+	{{- end }}
 )
 
 func Reg{{ .entity }}Router(r *gin.Engine) {
@@ -1,12 +1,18 @@
 module github.com/daodao97/egin-tools
 
-go 1.13
+go 1.25.0
 
 require (
-	github.com/armon/go-metrics v0.3.4 // indirect
 	github.com/daodao97/egin v0.0.0-20200909034326-ad0add3efa8a
 	github.com/davecgh/go-spew v1.1.1
-	github.com/elazarl/go-bindata-assetfs v1.0.1
+	github.com/fsnotify/fsnotify v1.7.0
+	github.com/pkg/errors v0.9.1
+	golang.org/x/tools v0.47.0
+	gopkg.in/yaml.v2 v2.3.0
+)
+
+require (
+	github.com/armon/go-metrics v0.3.4 // indirect
 	github.com/hashicorp/consul/api v1.7.0 // indirect
 	github.com/hashicorp/go-hclog v0.14.1 // indirect
 	github.com/hashicorp/go-immutable-radix v1.2.0 // indirect
@@ -15,9 +21,9 @@ require (
 	github.com/lestrrat-go/file-rotatelogs v2.4.0+incompatible // indirect
 	github.com/mattn/go-colorable v0.1.7 // indirect
 	github.com/mitchellh/mapstructure v1.3.3 // indirect
-	github.com/pkg/errors v0.9.1
 	github.com/techxmind/location2ip v1.0.1 // indirect
-	golang.org/x/sys v0.0.0-20200909081042-eff7692f9009 // indirect
+	golang.org/x/mod v0.37.0 // indirect
+	golang.org/x/sync v0.21.0 // indirect
+	golang.org/x/sys v0.46.0 // indirect
 	google.golang.org/protobuf v1.25.0 // indirect
-	gopkg.in/yaml.v2 v2.3.0 // indirect
 )
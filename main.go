@@ -1,19 +1,23 @@
 package main
 
 import (
-	"encoding/json"
+	"bytes"
+	"embed"
 	"flag"
 	"fmt"
+	"html/template"
+	"io/fs"
 	"net/http"
 	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
 
-	assetfs "github.com/elazarl/go-bindata-assetfs"
 	"github.com/pkg/errors"
 
-	"github.com/daodao97/egin/lib"
-
-	"github.com/daodao97/egin-tools/asset"
-
+	"github.com/daodao97/egin-tools/diag"
 	"github.com/daodao97/egin-tools/gen"
 	"github.com/daodao97/egin-tools/parser"
 	"github.com/daodao97/egin-tools/swagger"
@@ -22,23 +26,45 @@ import (
 var genDoc = flag.Bool("swagger", false, "是否生成 swagger.json 文件, 默认否")
 var startUi = flag.Bool("ui", false, "是否开启 swagger ui 的 http 服务")
 var uiPort = flag.String("ui-port", "8000", "swagger ui的监听端口")
+var uiHost = flag.String("ui-host", "", "swagger ui的监听地址, 为空时监听所有地址")
+var uiTheme = flag.String("ui-theme", "swagger", "文档页面的主题, 可选 swagger, redoc, rapidoc")
+var uiDir = flag.String("ui-dir", "", "自定义文档页面的目录, 其中的 index.html 作为模板渲染, 指定后忽略 -ui-theme")
+var uiBasePath = flag.String("ui-base-path", "/", "swagger ui的路径前缀, 如 /docs/, 用于在网关后访问")
 var genRoute = flag.Bool("route", false, "是否生成文件对应的路由文件")
 var genModelMode = flag.Bool("model", false, "根据mysql表结构生成数据模型")
 var connection = flag.String("connection", "default", "数据库丽连接名")
 var database = flag.String("database", "", "数据库名")
 var genCtrl = flag.Bool("controller", false, "创建控制器")
 var table = flag.String("table", "", "表名")
+var noCache = flag.Bool("no-cache", false, "不使用解析缓存, 重新解析所有文件")
+var specVersion = flag.String("spec-version", swagger.Version2, "文档版本, 2.0 为 Swagger 2.0, 3.1 为 OpenAPI 3.1")
+var output = flag.String("output", "docs", "文档的输出目录, 为空时不写入文件")
+var configFile = flag.String("config", swagger.DefaultConfigFile, "文档配置文件, 不存在时忽略")
+var env = flag.String("env", "", "文档中服务地址所属的环境, 为空时列出所有环境")
+var format = flag.String("format", swagger.FormatJSON, "文档的文件格式, json 或 yaml, 多个以逗号分隔")
+var proxyTarget = flag.String("proxy", "", "ui 服务将接口请求转发到的地址, 如 http://localhost:8080")
+var proxyHeader proxyHeaders
+var watchMode = flag.Bool("watch", false, "监听源码变更, 自动重新生成文档并刷新 ui 页面, 与 -swagger -ui 同时使用")
+var specList specSources
+var patterns = patternFlag{}
+var merge = flag.Bool("merge", false, "在 ui 中额外提供合并所有服务的文档, 路径以 /服务名 为前缀")
+
+func init() {
+	flag.Var(patterns, "pattern", "自定义校验规则对应的正则, 如 mobile=^1[3-9]\\d{9}$, 可重复使用")
+	flag.Var(&specList, "spec", "在 ui 中展示的其他服务, 如 user=../user-service 或 order=../order/docs/swagger.json, 可重复使用")
+	flag.Var(&proxyHeader, "proxy-header", "转发接口请求时注入的请求头, 如 \"Authorization: Bearer xxx\", 可重复使用")
+}
+
+// apidoc 当前的文档, 监听模式下会被重新生成, 通过 setApidoc, getApidoc 读写
 var apidoc interface{}
+var apidocMu sync.RWMutex
+var prog *parser.Program
 
-// go:generate go-bindata-assetfs -o=asset/asset.go -pkg=asset ui/...
 func main() {
 	flag.Parse()
 
 	if *genDoc {
 		genSwagger()
-		if *startUi {
-			ui()
-		}
 	}
 
 	if *genRoute {
@@ -52,67 +78,244 @@ func main() {
 	if *genCtrl {
 		genController()
 	}
+
+	if len(specList) > 0 {
+		loadSpecs()
+	}
+
+	// 所有问题统一输出, 仅有 error 时以非零状态退出
+	diag.Print(os.Stderr)
+
+	if *startUi && (*genDoc || len(specList) > 0) {
+		ui()
+	}
+
+	if diag.HasErrors() {
+		os.Exit(1)
+	}
 }
 
 func ui() {
 
-	files := assetfs.AssetFS{
-		Asset:    asset.Asset,
-		AssetDir: asset.AssetDir,
-		Prefix:   "ui", // 访问文件1.html = > 访问文件 www/1.html
+	files, err := uiFiles()
+	onErr(err)
+	content, err := fs.ReadFile(files, "index.html")
+	onErr(errors.Wrap(err, "read ui index.html"))
+	index, err := template.New("index").Parse(string(content))
+	onErr(errors.Wrap(err, "parse ui index.html"))
+
+	// 当前项目以目录名作为文档名, 与 -spec 的其他服务一同展示
+	var project string
+	if *genDoc {
+		project = filepath.Base(prog.Dir)
+	}
+	for _, s := range specList {
+		if s.Name == project {
+			onErr(errors.Errorf("spec name %s conflicts with the current project", s.Name))
+		}
+	}
+	if *merge {
+		_, err := swagger.Merge(services(project))
+		onErr(errors.Wrap(err, "merge specs"))
+	}
+
+	// 以 /docs/ 为前缀时, 访问 /docs 会被重定向到 /docs/
+	base := strings.TrimSuffix("/"+strings.Trim(*uiBasePath, "/"), "/")
+
+	// 开启转发时, 文档页面以外的请求转发到 -proxy, 没有前缀时页面中不存在的文件也会转发
+	var api, fallback http.Handler
+	if *proxyTarget != "" {
+		api, err = newProxy(*proxyTarget, proxyHeader)
+		onErr(err)
+		if base == "" {
+			fallback = api
+		}
 	}
 
-	http.Handle("/", http.FileServer(&files))
-	http.HandleFunc("/swagger.json", func(w http.ResponseWriter, req *http.Request) {
-		js, err := json.Marshal(apidoc)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
+	// 页面中使用相对地址加载文档, 经网关转发或修改端口后仍然可用
+	fileServer := http.FileServer(http.FS(files))
+	handler := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/", "/index.html":
+			var buf bytes.Buffer
+			data := map[string]interface{}{"SpecUrl": "./swagger.json", "Specs": specUrls(services(project))}
+			if err := index.Execute(&buf, data); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			page := buf.Bytes()
+			if *watchMode {
+				page = injectReload(page)
+			}
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			_, _ = w.Write(page)
+		case "/events":
+			if !*watchMode {
+				http.NotFound(w, req)
+				return
+			}
+			reload.ServeHTTP(w, req)
+		case "/swagger.json":
+			writeDoc(w, services(project)[0].Doc)
+		default:
+			if name := strings.TrimPrefix(req.URL.Path, "/specs/"); name != req.URL.Path && strings.HasSuffix(name, ".json") {
+				doc, err := specDoc(strings.TrimSuffix(name, ".json"), services(project))
+				if err != nil {
+					http.Error(w, err.Error(), http.StatusInternalServerError)
+					return
+				}
+				if doc == nil {
+					http.NotFound(w, req)
+					return
+				}
+				writeDoc(w, doc)
+				return
+			}
+			if _, err := fs.Stat(files, strings.TrimPrefix(req.URL.Path, "/")); err != nil && fallback != nil {
+				fallback.ServeHTTP(w, req)
+				return
+			}
+			fileServer.ServeHTTP(w, req)
 		}
-		w.Header().Set("Content-Type", "application/json")
-		_, _ = w.Write(js)
 	})
-	fmt.Println("SwaggerUI已启动, 使用 http://localhost:" + *uiPort + " 打开")
-	err := http.ListenAndServe(":"+*uiPort, nil)
+
+	mux := http.NewServeMux()
+	mux.Handle(base+"/", http.StripPrefix(base, handler))
+	if api != nil && base != "" {
+		mux.Handle("/", api)
+	}
+
+	host := *uiHost
+	if host == "" {
+		host = "localhost"
+	}
+	fmt.Println("SwaggerUI已启动, 使用 http://" + host + ":" + *uiPort + base + "/ 打开")
+	if api != nil {
+		fmt.Println("接口请求将转发到 " + *proxyTarget)
+	}
+	if *watchMode && *genDoc {
+		go watchSource()
+	}
+	err = http.ListenAndServe(*uiHost+":"+*uiPort, mux)
 	onErr(err)
 }
 
+// writeDoc 以 json 输出文档, 开启转发时服务地址改为文档页面的来源
+func writeDoc(w http.ResponseWriter, doc interface{}) {
+	if *proxyTarget != "" {
+		doc = proxied(doc)
+	}
+	js, err := swagger.Marshal(doc, swagger.FormatJSON)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(js)
+}
+
+// uiFS 内置的文档页面, ui 下每个目录为一种主题. ReDoc 与 RapiDoc 的脚本由 go generate 下载固定的版本,
+// 随二进制一起发布, 离线时同样可用
+//
+//go:generate curl -fsSL -o ui/redoc/redoc.standalone.js https://cdn.jsdelivr.net/npm/redoc@2.1.5/bundles/redoc.standalone.js
+//go:generate curl -fsSL -o ui/rapidoc/rapidoc-min.js https://cdn.jsdelivr.net/npm/rapidoc@9.3.8/dist/rapidoc-min.js
+//go:embed ui
+var uiFS embed.FS
+
+// uiFiles 文档页面的文件, 指定 -ui-dir 时使用该目录, 否则使用 -ui-theme 对应的内置主题.
+// 目录中的 index.html 作为模板渲染, {{ .SpecUrl }} 为文档的地址, 有多个文档时 {{ .Specs }} 为各文档的名称与地址
+func uiFiles() (fs.FS, error) {
+	if *uiDir != "" {
+		return os.DirFS(*uiDir), nil
+	}
+	if _, err := fs.Stat(uiFS, "ui/"+*uiTheme+"/index.html"); err != nil {
+		return nil, errors.Errorf("unknown ui theme %q, want swagger, redoc or rapidoc", *uiTheme)
+	}
+	return fs.Sub(uiFS, "ui/"+*uiTheme)
+}
+
 func genSwagger() {
+	doc, err := buildSwagger(program())
+	onErr(err)
+	setApidoc(doc)
+	onErr(writeSwagger(doc))
+}
 
-	openApi := swagger.NewSwagger()
+// buildSwagger 由解析结果生成指定版本的文档
+func buildSwagger(prog *parser.Program) (interface{}, error) {
+	spec := swagger.NewSpec()
 
-	AllPath := make(swagger.Paths)
-	var AllTags []swagger.Tags
+	// 配置文件优先于 main 函数上的注解
+	swagger.General(spec, prog, *env)
+	file := *configFile
+	if !filepath.IsAbs(file) {
+		file = filepath.Join(prog.Dir, file)
+	}
+	conf, err := swagger.LoadConfig(file)
+	if err != nil {
+		return nil, err
+	}
+	spec.ApplyConfig(conf, *env)
+	// 命令行中的规则优先于配置文件
+	for name, pattern := range patterns {
+		spec.Patterns[name] = pattern
+	}
 
-	lib.RecursiveDir("controller", func(filePath string) {
-		structInfo, err := parser.FileStructInfo(filePath)
-		onErr(err)
-		paths, tags := swagger.Filter(structInfo)
-		for p, v := range paths {
-			if _, ok := AllPath[p]; !ok {
-				AllPath[p] = make(map[swagger.Method]swagger.Api)
-			}
-			for m, api := range v {
-				AllPath[p][m] = api
-			}
-		}
-		AllTags = append(AllTags, tags...)
-	})
+	for _, file := range prog.FilesIn("controller") {
+		swagger.Filter(spec, file.Structs, prog)
+	}
+	return spec.Document(*specVersion)
+}
+
+// writeSwagger 将文档写入 -output 目录
+func writeSwagger(doc interface{}) error {
+	if *output == "" {
+		return nil
+	}
+	files, err := swagger.Write(doc, *output, strings.Split(*format, ","))
+	for _, v := range files {
+		fmt.Println(v)
+	}
+	return err
+}
 
-	openApi.Paths = AllPath
-	openApi.Tags = AllTags
-	apidoc = openApi
+func setApidoc(doc interface{}) {
+	apidocMu.Lock()
+	defer apidocMu.Unlock()
+	apidoc = doc
+}
+
+func getApidoc() interface{} {
+	apidocMu.RLock()
+	defer apidocMu.RUnlock()
+	return apidoc
+}
+
+// program 加载并解析整个模块, 各生成器共用同一份解析结果
+func program() *parser.Program {
+	if prog != nil {
+		return prog
+	}
+	var err error
+	prog, err = loadProgram(".")
+	onErr(err)
+	return prog
+}
+
+// loadProgram 重新解析 dir 下的模块, 使用缓存时只解析内容有变化的包
+func loadProgram(dir string) (*parser.Program, error) {
+	var cache *parser.Cache
+	if !*noCache {
+		cache = parser.NewCache(filepath.Join(dir, parser.DefaultCacheDir))
+	}
+	return parser.Load(dir, cache)
 }
 
 func genRouter() {
-	lib.RecursiveDir("controller", func(filePath string) {
-		structInfo, err := parser.FileStructInfo(filePath)
-		onErr(err)
-		varsInfo, err := parser.FileVarInfo(filePath)
-		onErr(err)
-		fmt.Println(filePath)
-		gen.MakeRouteFile(structInfo, varsInfo)
-	})
+	for _, file := range program().FilesIn("controller") {
+		fmt.Println(file.Path)
+		gen.MakeRouteFile(file.Structs, file.Vars, prog)
+	}
 	gen.MakeRouteExport()
 }
 
@@ -136,8 +339,34 @@ func genController() {
 	gen.MakeController(*table, "")
 }
 
+// patternFlag 登记 binding 标签中自定义校验规则的正则, 生成文档时作为 pattern 输出
+type patternFlag map[string]string
+
+func (p patternFlag) String() string {
+	var list []string
+	for name, pattern := range p {
+		list = append(list, name+"="+pattern)
+	}
+	sort.Strings(list)
+	return strings.Join(list, ", ")
+}
+
+func (p patternFlag) Set(v string) error {
+	i := strings.Index(v, "=")
+	if i <= 0 {
+		return errors.Errorf("invalid pattern %q, want name=regexp", v)
+	}
+	if _, err := regexp.Compile(v[i+1:]); err != nil {
+		return errors.Wrapf(err, "invalid pattern %s", v[:i])
+	}
+	p[v[:i]] = v[i+1:]
+	return nil
+}
+
+// onErr 遇到无法继续的错误时, 先输出已收集的诊断再退出
 func onErr(err error) {
 	if err != nil {
+		diag.Print(os.Stderr)
 		fmt.Println(err)
 		os.Exit(1)
 	}
@@ -0,0 +1,115 @@
+package parser
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/tools/go/packages"
+)
+
+// cacheVersion 解析结果的结构发生变化时需要修改, 使旧缓存失效
+const cacheVersion = "10"
+
+// DefaultCacheDir 相对于项目根目录的缓存目录
+const DefaultCacheDir = ".egin-tools/cache"
+
+// Cache 缓存单个文件的解析结果, 每个文件只保留一份, 所在的包或其依赖变化后覆盖旧的缓存
+type Cache struct {
+	Dir string
+}
+
+// cacheEntry 缓存的内容, Hash 为解析时文件所在包的摘要
+type cacheEntry struct {
+	Hash string
+	File FileInfo
+}
+
+func NewCache(dir string) *Cache {
+	return &Cache{Dir: dir}
+}
+
+// name 文件对应的缓存文件名
+func (c *Cache) name(file string, pkgPath string) string {
+	h := sha256.Sum256([]byte(pkgPath + "\x00" + file))
+	return hex.EncodeToString(h[:])
+}
+
+// Get 文件所在的包及其依赖均未变化时返回缓存的解析结果, digest 见 packageDigest
+func (c *Cache) Get(file string, pkgPath string, digest string) (*FileInfo, bool) {
+	data, err := ioutil.ReadFile(filepath.Join(c.Dir, c.name(file, pkgPath)))
+	if err != nil {
+		return nil, false
+	}
+	var entry cacheEntry
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&entry); err != nil || entry.Hash != digest {
+		return nil, false
+	}
+	return &entry.File, true
+}
+
+// Put 写入缓存, 失败时忽略, 下次运行重新解析即可
+func (c *Cache) Put(fi *FileInfo, digest string) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(cacheEntry{Hash: digest, File: *fi}); err != nil {
+		return
+	}
+	if err := os.MkdirAll(c.Dir, os.ModePerm); err != nil {
+		return
+	}
+	_ = ioutil.WriteFile(filepath.Join(c.Dir, c.name(fi.Path, fi.PkgPath)), buf.Bytes(), os.FileMode(0644))
+}
+
+// packageDigest 包及其所有依赖的摘要. 常量与枚举的值由类型检查得出, 可能引用其他包的常量,
+// 因此依赖包变化时依赖它的包的缓存同样失效. dir 下的文件按内容计算, 其他文件(标准库, 模块缓存)
+// 只取路径, 大小与修改时间
+func packageDigest(pkg *packages.Package, dir string, memo map[string]string) string {
+	if d, ok := memo[pkg.ID]; ok {
+		return d
+	}
+	h := sha256.New()
+	h.Write([]byte(cacheVersion + "\x00" + pkg.PkgPath + "\x00"))
+	for _, file := range pkg.GoFiles {
+		h.Write([]byte(file + "\x00"))
+		if rel, err := filepath.Rel(dir, file); err == nil && !strings.HasPrefix(rel, "..") {
+			content, _ := ioutil.ReadFile(file)
+			h.Write(content)
+		} else if info, err := os.Stat(file); err == nil {
+			fmt.Fprintf(h, "%d %d", info.Size(), info.ModTime().UnixNano())
+		}
+	}
+	paths := make([]string, 0, len(pkg.Imports))
+	for path := range pkg.Imports {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		h.Write([]byte(path + "\x00" + packageDigest(pkg.Imports[path], dir, memo)))
+	}
+	memo[pkg.ID] = hex.EncodeToString(h.Sum(nil))
+	return memo[pkg.ID]
+}
+
+// Prune 删除不属于 files 的缓存, 文件被删除或移动后其缓存不再保留
+func (c *Cache) Prune(files []*FileInfo) {
+	keep := make(map[string]bool, len(files))
+	for _, f := range files {
+		keep[c.name(f.Path, f.PkgPath)] = true
+	}
+	list, err := ioutil.ReadDir(c.Dir)
+	if err != nil {
+		return
+	}
+	for _, v := range list {
+		if !v.IsDir() && !keep[v.Name()] {
+			_ = os.Remove(filepath.Join(c.Dir, v.Name()))
+		}
+	}
+}
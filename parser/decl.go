@@ -0,0 +1,277 @@
+package parser
+
+import (
+	"go/ast"
+	"go/constant"
+	"go/token"
+	"go/types"
+	"strconv"
+)
+
+// TypeInfo 非结构体, 非接口的命名类型, 如 type Status int
+type TypeInfo struct {
+	Name    string
+	PkgPath string
+	File    string
+	Pos     token.Position
+	// Type 底层类型
+	Type *Type
+	Doc  []string
+	// TypeParams 泛型类型的类型参数名
+	TypeParams []string
+}
+
+// Instantiate 以类型实参替换底层类型中的类型参数
+func (t TypeInfo) Instantiate(args []*Type) TypeInfo {
+	if len(t.TypeParams) == 0 {
+		return t
+	}
+	m := make(map[string]*Type, len(t.TypeParams))
+	for i, a := range args {
+		if i < len(t.TypeParams) {
+			m[t.TypeParams[i]] = a
+		}
+	}
+	t.Type = t.Type.Subst(m)
+	t.TypeParams = nil
+	return t
+}
+
+// FullName 类型的全限定名
+func (t TypeInfo) FullName() string {
+	if t.PkgPath == "" {
+		return t.Name
+	}
+	return t.PkgPath + "." + t.Name
+}
+
+// ConstInfo 常量
+type ConstInfo struct {
+	Name string
+	// Type 声明的类型, 未声明类型时为 nil; 分组中省略的类型沿用上一项
+	Type *Type
+	// Value 常量值的 Go 字面量形式, 如 1, "active"
+	Value   string
+	Pos     token.Position
+	Doc     []string
+	Comment []string
+}
+
+// Literal 将常量值转为 int64, float64, bool 或 string, 无法识别时返回原始文本
+func (c ConstInfo) Literal() interface{} {
+	if v, err := strconv.ParseInt(c.Value, 0, 64); err == nil {
+		return v
+	}
+	if v, err := strconv.ParseFloat(c.Value, 64); err == nil {
+		return v
+	}
+	if v, err := strconv.ParseBool(c.Value); err == nil {
+		return v
+	}
+	if v, err := strconv.Unquote(c.Value); err == nil {
+		return v
+	}
+	return c.Value
+}
+
+// ConstGroup 一个 const 声明块
+type ConstGroup struct {
+	Pos    token.Position
+	Doc    []string
+	Consts []ConstInfo
+}
+
+// InterfaceMethod 接口中的方法
+type InterfaceMethod struct {
+	Name    string
+	Params  []FuncParam
+	Results []FuncParam
+	Doc     []string
+}
+
+// InterfaceInfo 接口
+type InterfaceInfo struct {
+	Name    string
+	PkgPath string
+	File    string
+	Pos     token.Position
+	Doc     []string
+	Methods []InterfaceMethod
+	// Embeds 嵌入的接口或类型约束
+	Embeds []*Type
+}
+
+// FullName 接口的全限定名
+func (t InterfaceInfo) FullName() string {
+	if t.PkgPath == "" {
+		return t.Name
+	}
+	return t.PkgPath + "." + t.Name
+}
+
+// getTypes 文件中的非结构体命名类型与接口
+func (s *fileScope) getTypes(f *ast.File) (named []TypeInfo, interfaces []InterfaceInfo) {
+	for _, item := range f.Decls {
+		obj, ok := item.(*ast.GenDecl)
+		if !ok || obj.Tok != token.TYPE {
+			continue
+		}
+		for _, v := range obj.Specs {
+			spec, ok := v.(*ast.TypeSpec)
+			if !ok {
+				continue
+			}
+			doc := spec.Doc
+			if doc == nil && len(obj.Specs) == 1 {
+				doc = obj.Doc
+			}
+			var docs []string
+			if doc != nil {
+				docs = getComment(doc)
+			}
+			typeParams := typeParamNames(spec.TypeParams)
+			s.setTypeParams(typeParams)
+			switch t := spec.Type.(type) {
+			case *ast.StructType:
+				// 结构体由 getStruct 处理
+			case *ast.InterfaceType:
+				interfaces = append(interfaces, InterfaceInfo{
+					Name:    spec.Name.Name,
+					PkgPath: s.pkgPath,
+					File:    s.file,
+					Pos:     s.position(spec.Pos(), doc),
+					Doc:     docs,
+					Methods: s.getInterfaceMethods(t),
+					Embeds:  s.getInterfaceEmbeds(t),
+				})
+			default:
+				named = append(named, TypeInfo{
+					Name:       spec.Name.Name,
+					PkgPath:    s.pkgPath,
+					File:       s.file,
+					Pos:        s.position(spec.Pos(), doc),
+					Type:       s.typeOf(spec.Type),
+					Doc:        docs,
+					TypeParams: typeParams,
+				})
+			}
+			s.setTypeParams(nil)
+		}
+	}
+	return named, interfaces
+}
+
+func (s *fileScope) getInterfaceMethods(it *ast.InterfaceType) (result []InterfaceMethod) {
+	for _, m := range it.Methods.List {
+		ft, ok := m.Type.(*ast.FuncType)
+		if !ok || len(m.Names) == 0 {
+			continue
+		}
+		var docs []string
+		if m.Doc != nil {
+			docs = getComment(m.Doc)
+		}
+		result = append(result, InterfaceMethod{
+			Name:    m.Names[0].Name,
+			Params:  s.getFieldList(ft.Params),
+			Results: s.getFieldList(ft.Results),
+			Doc:     docs,
+		})
+	}
+	return result
+}
+
+func (s *fileScope) getInterfaceEmbeds(it *ast.InterfaceType) (result []*Type) {
+	for _, m := range it.Methods.List {
+		if _, ok := m.Type.(*ast.FuncType); ok && len(m.Names) > 0 {
+			continue
+		}
+		result = append(result, s.typeOf(m.Type))
+	}
+	return result
+}
+
+// getFieldList 参数或返回值列表, A, B int 拆分为多项
+func (s *fileScope) getFieldList(list *ast.FieldList) (result []FuncParam) {
+	if list == nil {
+		return result
+	}
+	for _, v := range list.List {
+		if len(v.Names) == 0 {
+			result = append(result, FuncParam{Type: s.typeOf(v.Type)})
+			continue
+		}
+		for _, n := range v.Names {
+			result = append(result, FuncParam{Name: n.Name, Type: s.typeOf(v.Type)})
+		}
+	}
+	return result
+}
+
+// getConsts 文件中的 const 声明块, 有类型信息时常量值由类型检查计算, 可正确处理 iota
+func (s *fileScope) getConsts(f *ast.File) (result []ConstGroup) {
+	for _, item := range f.Decls {
+		obj, ok := item.(*ast.GenDecl)
+		if !ok || obj.Tok != token.CONST {
+			continue
+		}
+		group := ConstGroup{Pos: s.position(obj.Pos(), obj.Doc)}
+		if obj.Doc != nil {
+			group.Doc = getComment(obj.Doc)
+		}
+		// 分组中省略类型与值的项沿用上一项的类型与表达式
+		var lastType ast.Expr
+		var lastValues []ast.Expr
+		for _, v := range obj.Specs {
+			spec, ok := v.(*ast.ValueSpec)
+			if !ok {
+				continue
+			}
+			if spec.Type != nil || len(spec.Values) > 0 {
+				lastType, lastValues = spec.Type, spec.Values
+			}
+			var docs, comments []string
+			if spec.Doc != nil {
+				docs = getComment(spec.Doc)
+			}
+			if spec.Comment != nil {
+				comments = getComment(spec.Comment)
+			}
+			for i, name := range spec.Names {
+				if name.Name == "_" {
+					continue
+				}
+				c := ConstInfo{
+					Name:    name.Name,
+					Pos:     s.position(name.Pos(), nil),
+					Doc:     docs,
+					Comment: comments,
+					Value:   s.constValue(name, lastValues, i),
+				}
+				if lastType != nil {
+					c.Type = s.typeOf(lastType)
+				}
+				group.Consts = append(group.Consts, c)
+			}
+		}
+		if len(group.Consts) > 0 {
+			result = append(result, group)
+		}
+	}
+	return result
+}
+
+func (s *fileScope) constValue(name *ast.Ident, values []ast.Expr, i int) string {
+	if s.info != nil {
+		if c, ok := s.info.Defs[name].(*types.Const); ok && c.Val().Kind() != constant.Unknown {
+			return c.Val().ExactString()
+		}
+	}
+	if i < len(values) {
+		if lit, ok := values[i].(*ast.BasicLit); ok {
+			return lit.Value
+		}
+		return exprString(values[i])
+	}
+	return ""
+}
@@ -0,0 +1,402 @@
+package parser
+
+import (
+	goparser "go/parser"
+	"go/token"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/tools/go/packages"
+
+	"github.com/daodao97/egin-tools/diag"
+)
+
+const (
+	listMode = packages.NeedName | packages.NeedFiles
+	loadMode = listMode | packages.NeedImports | packages.NeedSyntax | packages.NeedTypes | packages.NeedTypesInfo
+)
+
+// FileInfo 单个文件的解析结果
+type FileInfo struct {
+	Path    string
+	PkgPath string
+	// Imports 包名 => 导入路径
+	Imports map[string]string
+	// Structs 结构体, 其 Funcs 由 Program 从包内所有文件的 Methods 中汇总
+	Structs []StructInfo
+	// Methods 文件中声明的方法
+	Methods []StructFunc
+	// Types 非结构体, 非接口的命名类型
+	Types      []TypeInfo
+	Interfaces []InterfaceInfo
+	Consts     []ConstGroup
+	Vars       []VarInfo
+	Funcs      []FuncInfo
+}
+
+// Program 整个模块的解析结果, 结构体按全限定名索引, 可跨文件跨包查找
+type Program struct {
+	Dir     string
+	Files   []*FileInfo
+	files   map[string]*FileInfo
+	structs map[string]StructInfo
+	types   map[string]TypeInfo
+	ifaces  map[string]InterfaceInfo
+	// enums 命名类型的全限定名 => 该类型的常量
+	enums map[string][]ConstInfo
+}
+
+// Load 以 go/packages 加载 dir 下匹配 patterns 的所有包, 默认加载整个模块.
+// cache 不为空时, 自身及依赖均未变化的包直接使用缓存, 其他包重新做类型检查
+func Load(dir string, cache *Cache, patterns ...string) (*Program, error) {
+	// 加载整个模块时才能判断哪些缓存已不再使用
+	all := len(patterns) == 0
+	if all {
+		patterns = []string{"./..."}
+	}
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	prog := &Program{
+		Dir:     dir,
+		files:   make(map[string]*FileInfo),
+		structs: make(map[string]StructInfo),
+		types:   make(map[string]TypeInfo),
+		ifaces:  make(map[string]InterfaceInfo),
+		enums:   make(map[string][]ConstInfo),
+	}
+
+	toLoad := patterns
+	var digests map[string]string
+	if cache != nil {
+		toLoad, digests, err = prog.loadCached(cache, patterns)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(toLoad) > 0 {
+		cfg := &packages.Config{Mode: loadMode, Dir: dir}
+		pkgs, err := packages.Load(cfg, toLoad...)
+		if err != nil {
+			return nil, errors.Wrapf(err, "load packages in %s", dir)
+		}
+		for _, pkg := range pkgs {
+			reportErrors(pkg)
+			for _, fi := range parsePackage(pkg) {
+				// 有语法错误的包不缓存, 否则下次命中缓存时错误不再报告
+				if digest, ok := digests[pkg.PkgPath]; ok && !hasSyntaxErrors(pkg) {
+					cache.Put(fi, digest)
+				}
+				prog.addFile(fi)
+			}
+		}
+	}
+
+	prog.build()
+	if cache != nil && all {
+		cache.Prune(prog.Files)
+	}
+	return prog, nil
+}
+
+// loadCached 只列出包, 文件与依赖, 全部文件命中缓存的包直接使用缓存,
+// 返回需要重新加载的包及各包的摘要
+func (p *Program) loadCached(cache *Cache, patterns []string) (toLoad []string, digests map[string]string, err error) {
+	cfg := &packages.Config{Mode: listMode | packages.NeedImports | packages.NeedDeps, Dir: p.Dir}
+	pkgs, err := packages.Load(cfg, patterns...)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "list packages in %s", p.Dir)
+	}
+	digests = make(map[string]string, len(pkgs))
+	memo := make(map[string]string)
+	for _, pkg := range pkgs {
+		digest := packageDigest(pkg, p.Dir, memo)
+		digests[pkg.PkgPath] = digest
+		var cached []*FileInfo
+		for _, file := range pkg.GoFiles {
+			fi, ok := cache.Get(file, pkg.PkgPath, digest)
+			if !ok {
+				break
+			}
+			cached = append(cached, fi)
+		}
+		if len(cached) != len(pkg.GoFiles) {
+			toLoad = append(toLoad, pkg.PkgPath)
+			continue
+		}
+		for _, fi := range cached {
+			p.addFile(fi)
+		}
+	}
+	return toLoad, digests, nil
+}
+
+// parsePackage 解析包内的每个文件
+func parsePackage(pkg *packages.Package) (result []*FileInfo) {
+	for _, f := range pkg.Syntax {
+		scope := newPackageScope(pkg.Fset, f, pkg.PkgPath, pkg.TypesInfo)
+		named, interfaces := scope.getTypes(f)
+		result = append(result, &FileInfo{
+			Path:       scope.file,
+			PkgPath:    pkg.PkgPath,
+			Imports:    scope.imports,
+			Structs:    scope.getStruct(f),
+			Methods:    scope.getMethods(f),
+			Types:      named,
+			Interfaces: interfaces,
+			Consts:     scope.getConsts(f),
+			Vars:       getVarsInfo(f),
+			Funcs:      scope.getFuncInfo(f),
+		})
+	}
+	return result
+}
+
+func (p *Program) addFile(fi *FileInfo) {
+	p.Files = append(p.Files, fi)
+	p.files[fi.Path] = fi
+}
+
+// build 汇总方法并处理嵌入字段, 方法可能声明在包内的其他文件中, 被嵌入的结构体可能来自其他包
+func (p *Program) build() {
+	sort.Slice(p.Files, func(i, j int) bool {
+		return p.Files[i].Path < p.Files[j].Path
+	})
+	methods := make(map[string][]StructFunc)
+	for _, f := range p.Files {
+		for _, m := range f.Methods {
+			key := f.PkgPath + "." + m.Recv
+			methods[key] = append(methods[key], m)
+		}
+	}
+	for _, f := range p.Files {
+		for i, st := range f.Structs {
+			f.Structs[i].Funcs = methods[st.FullName()]
+			p.structs[st.FullName()] = f.Structs[i]
+		}
+		for _, t := range f.Types {
+			p.types[t.FullName()] = t
+		}
+		for _, t := range f.Interfaces {
+			p.ifaces[t.FullName()] = t
+		}
+		for _, g := range f.Consts {
+			for _, c := range g.Consts {
+				if c.Type == nil || c.Type.Kind != KindNamed {
+					continue
+				}
+				key := c.Type.Name
+				if c.Type.PkgPath != "" {
+					key = c.Type.PkgPath + "." + c.Type.Name
+				}
+				p.enums[key] = append(p.enums[key], c)
+			}
+		}
+	}
+
+	promoted := make(map[string]StructInfo, len(p.structs))
+	for _, f := range p.Files {
+		for i, st := range f.Structs {
+			f.Structs[i].Fields = promoteFields(st.Fields, p.Lookup, make(map[string]bool))
+			promoted[st.FullName()] = f.Structs[i]
+		}
+	}
+	p.structs = promoted
+}
+
+// reportErrors 类型检查错误不影响注解解析, 仅作为警告; 语法错误会导致声明缺失, 作为错误.
+// 没有位置的错误通常是其他错误的汇总, 只在没有带位置的错误时输出
+func reportErrors(pkg *packages.Package) {
+	positioned := false
+	for _, e := range pkg.Errors {
+		if e.Pos != "" && e.Pos != "-" {
+			positioned = true
+		}
+	}
+	for _, e := range pkg.Errors {
+		if positioned && (e.Pos == "" || e.Pos == "-") {
+			continue
+		}
+		if e.Kind == packages.ParseError {
+			diag.Errorf(errorPosition(e.Pos), diag.ParseError, "%s", e.Msg)
+			continue
+		}
+		diag.Warnf(errorPosition(e.Pos), diag.ParseError, "%s", e.Msg)
+	}
+}
+
+func hasSyntaxErrors(pkg *packages.Package) bool {
+	for _, e := range pkg.Errors {
+		if e.Kind == packages.ParseError {
+			return true
+		}
+	}
+	return false
+}
+
+// errorPosition 解析 packages.Error 中 file:line:col 形式的位置
+func errorPosition(pos string) (result token.Position) {
+	parts := strings.Split(pos, ":")
+	if len(parts) >= 3 {
+		result.Line, _ = strconv.Atoi(parts[len(parts)-2])
+		result.Column, _ = strconv.Atoi(parts[len(parts)-1])
+		parts = parts[:len(parts)-2]
+	}
+	if len(parts) == 2 {
+		if line, err := strconv.Atoi(parts[1]); err == nil {
+			result.Line = line
+			parts = parts[:1]
+		}
+	}
+	result.Filename = strings.Join(parts, ":")
+	return result
+}
+
+// FilesIn 返回 dir 目录(含子目录)下的文件
+func (p *Program) FilesIn(dir string) (result []*FileInfo) {
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(p.Dir, dir)
+	}
+	prefix := filepath.Clean(dir) + string(filepath.Separator)
+	for _, f := range p.Files {
+		if strings.HasPrefix(f.Path, prefix) {
+			result = append(result, f)
+		}
+	}
+	return result
+}
+
+// HasPackage 包是否已加载
+func (p *Program) HasPackage(pkgPath string) bool {
+	for _, f := range p.Files {
+		if f.PkgPath == pkgPath {
+			return true
+		}
+	}
+	return false
+}
+
+// Struct 按全限定名查找结构体, 如 github.com/foo/bar/dto.User
+func (p *Program) Struct(fullName string) (StructInfo, bool) {
+	s, ok := p.structs[fullName]
+	return s, ok
+}
+
+// Lookup 查找命名类型(可为指针)对应的结构体, 泛型实例如 Page[User] 返回替换类型参数后的结构体
+func (p *Program) Lookup(t *Type) (result StructInfo, ok bool) {
+	t = t.Deref()
+	if t == nil || t.Kind != KindNamed {
+		return result, false
+	}
+	if t.PkgPath == "" {
+		result, ok = p.Struct(t.Name)
+	} else {
+		result, ok = p.Struct(t.PkgPath + "." + t.Name)
+	}
+	if ok && len(t.Args) > 0 {
+		result = result.Instantiate(t.Args)
+	}
+	return result, ok
+}
+
+// NamedType 查找非结构体的命名类型, 如 type Status int, 泛型实例返回替换类型参数后的结果
+func (p *Program) NamedType(t *Type) (result TypeInfo, ok bool) {
+	if t == nil || t.Kind != KindNamed {
+		return result, false
+	}
+	if t.PkgPath == "" {
+		result, ok = p.types[t.Name]
+	} else {
+		result, ok = p.types[t.PkgPath+"."+t.Name]
+	}
+	if ok && len(t.Args) > 0 {
+		result = result.Instantiate(t.Args)
+	}
+	return result, ok
+}
+
+// Enum 以命名类型声明的常量, 按文件与声明顺序排列
+func (p *Program) Enum(t *Type) []ConstInfo {
+	named, ok := p.NamedType(t)
+	if !ok {
+		return nil
+	}
+	return p.enums[named.FullName()]
+}
+
+// Interface 查找接口
+func (p *Program) Interface(t *Type) (result InterfaceInfo, ok bool) {
+	if t == nil || t.Kind != KindNamed {
+		return result, false
+	}
+	if t.PkgPath == "" {
+		result, ok = p.ifaces[t.Name]
+	} else {
+		result, ok = p.ifaces[t.PkgPath+"."+t.Name]
+	}
+	return result, ok
+}
+
+// Implementations 实现了接口的结构体, 按方法名判断, 按全限定名排序.
+// 接口没有方法或嵌入了其他接口时无法判断, 返回 nil
+func (p *Program) Implementations(t *Type) (result []StructInfo) {
+	iface, ok := p.Interface(t)
+	if !ok || len(iface.Methods) == 0 || len(iface.Embeds) > 0 {
+		return nil
+	}
+	for _, st := range p.structs {
+		if len(st.TypeParams) == 0 && implements(st, iface) {
+			result = append(result, st)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].FullName() < result[j].FullName()
+	})
+	return result
+}
+
+func implements(st StructInfo, iface InterfaceInfo) bool {
+	funcs := make(map[string]bool, len(st.Funcs))
+	for _, f := range st.Funcs {
+		funcs[f.Name] = true
+	}
+	for _, m := range iface.Methods {
+		if !funcs[m.Name] {
+			return false
+		}
+	}
+	return true
+}
+
+// ResolveType 在 file 的上下文中解析类型表达式, 如注解中的 UserFilter, dto.User
+func (p *Program) ResolveType(expr string, file string) (*Type, error) {
+	x, err := goparser.ParseExpr(expr)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid type %q", expr)
+	}
+	scope := &fileScope{file: file, imports: make(map[string]string)}
+	if fi, ok := p.files[file]; ok {
+		scope.pkgPath = fi.PkgPath
+		scope.imports = fi.Imports
+	}
+	return scope.typeOf(x), nil
+}
+
+// ResolveStruct 在 file 的上下文中按名称查找结构体
+func (p *Program) ResolveStruct(name string, file string) (result StructInfo, err error) {
+	t, err := p.ResolveType(name, file)
+	if err != nil {
+		return result, err
+	}
+	result, ok := p.Lookup(t)
+	if !ok {
+		return result, errors.Errorf("struct %s not found", name)
+	}
+	return result, nil
+}
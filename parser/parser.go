@@ -4,32 +4,111 @@ import (
 	"go/ast"
 	"go/parser"
 	"go/token"
+	"strconv"
 	"strings"
+
+	"github.com/daodao97/egin-tools/annotation"
 )
 
 type StructInfo struct {
-	Name   string
+	Name    string
+	PkgPath string
+	File    string
+	// Pos 文档注释的起始位置, 无注释时为类型声明的位置
+	Pos    token.Position
 	Fields []StructField
 	Funcs  []StructFunc
-	Doc    []string
+	Doc    []annotation.Line
+	// TypeParams 泛型结构体的类型参数名
+	TypeParams []string
+}
+
+// FullName 结构体的全限定名, 如 github.com/foo/bar/dto.User
+func (s StructInfo) FullName() string {
+	if s.PkgPath == "" {
+		return s.Name
+	}
+	return s.PkgPath + "." + s.Name
+}
+
+// Instantiate 以类型实参实例化泛型结构体, 字段中的类型参数替换为实参, 名称变为 Page[User] 的形式
+func (s StructInfo) Instantiate(args []*Type) StructInfo {
+	if len(s.TypeParams) == 0 {
+		return s
+	}
+	m := make(map[string]*Type, len(s.TypeParams))
+	names := make([]string, len(args))
+	for i, a := range args {
+		if i < len(s.TypeParams) {
+			m[s.TypeParams[i]] = a
+		}
+		names[i] = a.String()
+	}
+	s.Fields = substFields(s.Fields, m)
+	s.Name += "[" + strings.Join(names, ", ") + "]"
+	s.TypeParams = nil
+	return s
 }
 
 type FuncParam struct {
 	Name string
-	Type string
+	Type *Type
+	// Doc 参数上方的注释, 参数分行书写时有效
+	Doc []string
+	// Comment 参数行尾的注释
+	Comment []string
+}
+
+// Description 参数说明, 上方注释优先于行尾注释
+func (p FuncParam) Description() string {
+	return describe(p.Name, p.Doc, p.Comment)
 }
 
 type StructFunc struct {
-	Name        string
-	Doc         []string
+	// Recv 接收者的类型名
+	Recv string
+	Name string
+	// Pos 文档注释的起始位置, 无注释时为函数声明的位置
+	Pos         token.Position
+	Doc         []annotation.Line
 	Params      []FuncParam
 	ResultCount int
+	// Results 返回值, (a, b int) 拆分为两项
+	Results []FuncParam
 }
 
 type StructField struct {
 	Name string
 	Tags map[string]string
-	Type string
+	Type *Type
+	Pos  token.Position
+	// Doc 字段上方的注释
+	Doc []string
+	// Comment 字段行尾的注释
+	Comment []string
+	// Embedded 匿名嵌入字段
+	Embedded bool
+}
+
+// Description 字段说明, 优先级为 label 标签 > 上方注释 > 行尾注释
+func (f StructField) Description() string {
+	if label, ok := f.Tags["label"]; ok {
+		return label
+	}
+	return describe(f.Name, f.Doc, f.Comment)
+}
+
+// describe 合并多行注释, 并去掉 godoc 风格注释开头的名称, 如 "// Id 用户ID"
+func describe(name string, doc []string, comment []string) string {
+	lines := doc
+	if len(lines) == 0 {
+		lines = comment
+	}
+	text := strings.TrimSpace(strings.Join(lines, " "))
+	if name != "" && strings.HasPrefix(text, name+" ") {
+		text = strings.TrimSpace(text[len(name):])
+	}
+	return text
 }
 
 type VarInfo struct {
@@ -39,41 +118,89 @@ type VarInfo struct {
 
 type FuncInfo struct {
 	Name string
+	// Pos 文档注释的起始位置, 无注释时为函数声明的位置
+	Pos token.Position
+	Doc []annotation.Line
 }
 
 // getComment 获取注释信息，来自AST标准库的summary方法
 func getComment(group *ast.CommentGroup) (list []string) {
 	for _, comment := range group.List {
-		// 注释信息会以 // 参数名，开始，我们实际使用时不需要，去掉
-		text := strings.TrimSpace(strings.TrimPrefix(comment.Text, "//"))
-		list = append(list, text)
+		// 注释信息会以 // 或 /* */ 包裹，我们实际使用时不需要，去掉
+		text := comment.Text
+		if strings.HasPrefix(text, "/*") {
+			text = strings.TrimSuffix(text[2:], "*/")
+		} else {
+			text = strings.TrimPrefix(text, "//")
+		}
+		for _, l := range strings.Split(text, "\n") {
+			list = append(list, strings.TrimSpace(l))
+		}
 	}
 
 	return list
 }
 
-func getStruct(f *ast.File) (result []StructInfo) {
+// getDoc 文档注释的各行及其位置, 块注释按行拆分, 注解的错误据此定位到具体的列
+func (s *fileScope) getDoc(group *ast.CommentGroup) (list []annotation.Line) {
+	if group == nil {
+		return nil
+	}
+	for _, c := range group.List {
+		pos := s.position(c.Slash, nil)
+		text := c.Text[2:]
+		if c.Text[1] == '*' {
+			text = strings.TrimSuffix(text, "*/")
+		}
+		pos.Column += 2
+		pos.Offset += 2
+		for i, l := range strings.Split(text, "\n") {
+			if i > 0 {
+				pos.Line++
+				pos.Column = 1
+			}
+			indent := len(l) - len(strings.TrimLeft(l, " \t"))
+			p := pos
+			p.Column += indent
+			p.Offset += indent
+			list = append(list, annotation.Line{Text: strings.TrimSpace(l), Pos: p})
+			pos.Offset += len(l) + 1
+		}
+	}
+	return list
+}
+
+func (s *fileScope) getStruct(f *ast.File) (result []StructInfo) {
 	for _, item := range f.Decls {
 		obj, ok := item.(*ast.GenDecl)
-		if !ok || len(obj.Specs) != 1 {
+		if !ok || obj.Tok != token.TYPE {
 			continue
 		}
-		s, ok := obj.Specs[0].(*ast.TypeSpec)
-		if !ok {
-			continue
-		}
-		name := s.Name.Name
-		body, ok := s.Type.(*ast.StructType)
-		var docs []string
-		if obj.Doc != nil {
-			docs = getComment(obj.Doc)
-		}
-		if ok {
+		for _, v := range obj.Specs {
+			spec, ok := v.(*ast.TypeSpec)
+			if !ok {
+				continue
+			}
+			body, ok := spec.Type.(*ast.StructType)
+			if !ok {
+				continue
+			}
+			// 分组声明 type ( A struct{}; B struct{} ) 时, 注释挂在各自的 spec 上
+			doc := spec.Doc
+			if doc == nil && len(obj.Specs) == 1 {
+				doc = obj.Doc
+			}
+			name := spec.Name.Name
 			var structInfo StructInfo
 			structInfo.Name = name
-			structInfo.Funcs = getStructFuncDoc(name, f)
-			structInfo.Fields = getStructFieldTag(body.Fields.List)
-			structInfo.Doc = docs
+			structInfo.PkgPath = s.pkgPath
+			structInfo.File = s.file
+			structInfo.Pos = s.position(spec.Pos(), doc)
+			structInfo.TypeParams = typeParamNames(spec.TypeParams)
+			s.setTypeParams(structInfo.TypeParams)
+			structInfo.Fields = s.getStructFieldTag(body.Fields.List)
+			s.setTypeParams(nil)
+			structInfo.Doc = s.getDoc(doc)
 			result = append(result, structInfo)
 		}
 	}
@@ -81,21 +208,44 @@ func getStruct(f *ast.File) (result []StructInfo) {
 	return result
 }
 
-func getStructFieldTag(fields []*ast.Field) (result []StructField) {
+func (s *fileScope) getStructFieldTag(fields []*ast.Field) (result []StructField) {
 	for _, v := range fields {
 		tags := make(map[string]string)
-		if len(v.Names) > 0 && v.Tag != nil {
-			tagParts := strings.Split(strings.Trim(v.Tag.Value, "`"), " ")
-			for _, v := range tagParts {
-				tag := strings.Split(v, ":")
-				if len(tag) == 2 {
-					tags[strings.TrimSpace(tag[0])] = strings.TrimSpace(strings.Trim(tag[1], "\""))
-				}
+		if v.Tag != nil {
+			if raw, err := strconv.Unquote(v.Tag.Value); err == nil {
+				tags = parseTag(raw)
 			}
+		}
+		var docs, comments []string
+		if v.Doc != nil {
+			docs = getComment(v.Doc)
+		}
+		if v.Comment != nil {
+			comments = getComment(v.Comment)
+		}
+		t := s.typeOf(v.Type)
+		if len(v.Names) == 0 {
+			// 匿名嵌入字段, 以类型名作为字段名, 字段提升在 promoteFields 中处理
 			result = append(result, StructField{
-				Name: v.Names[0].Name,
-				Tags: tags,
-				// Type: v.Type.(*ast.SelectorExpr).Sel.Name,
+				Name:     t.Deref().Name,
+				Tags:     tags,
+				Type:     t,
+				Pos:      s.position(v.Pos(), nil),
+				Doc:      docs,
+				Comment:  comments,
+				Embedded: true,
+			})
+			continue
+		}
+		// A, B int 这类声明拆分为多个字段
+		for _, name := range v.Names {
+			result = append(result, StructField{
+				Name:    name.Name,
+				Tags:    tags,
+				Type:    t,
+				Pos:     s.position(name.Pos(), nil),
+				Doc:     docs,
+				Comment: comments,
 			})
 		}
 	}
@@ -103,18 +253,71 @@ func getStructFieldTag(fields []*ast.Field) (result []StructField) {
 	return result
 }
 
-func getStructFuncDoc(structName string, f *ast.File) (result []StructFunc) {
+// parseTag 按 reflect.StructTag 的规则解析全部 key:"value" 对
+func parseTag(tag string) map[string]string {
+	tags := make(map[string]string)
+	for tag != "" {
+		// 跳过前导空白
+		i := 0
+		for i < len(tag) && tag[i] == ' ' {
+			i++
+		}
+		tag = tag[i:]
+		if tag == "" {
+			break
+		}
+
+		// key 为非空的非控制字符, 不含空格, 引号与冒号
+		i = 0
+		for i < len(tag) && tag[i] > ' ' && tag[i] != ':' && tag[i] != '"' && tag[i] != 0x7f {
+			i++
+		}
+		if i == 0 || i+1 >= len(tag) || tag[i] != ':' || tag[i+1] != '"' {
+			break
+		}
+		name := tag[:i]
+		tag = tag[i+1:]
+
+		// value 为带引号的字符串
+		i = 1
+		for i < len(tag) && tag[i] != '"' {
+			if tag[i] == '\\' {
+				i++
+			}
+			i++
+		}
+		if i >= len(tag) {
+			break
+		}
+		qvalue := tag[:i+1]
+		tag = tag[i+1:]
+
+		value, err := strconv.Unquote(qvalue)
+		if err != nil {
+			break
+		}
+		if _, ok := tags[name]; !ok {
+			tags[name] = value
+		}
+	}
+	return tags
+}
+
+// getMethods 文件中声明的所有方法
+func (s *fileScope) getMethods(f *ast.File) (result []StructFunc) {
+	var cmap ast.CommentMap
+	if s.fset != nil {
+		cmap = ast.NewCommentMap(s.fset, f, f.Comments)
+	}
 
 	for _, item := range f.Decls {
 		fun, ok := item.(*ast.FuncDecl)
-		if !ok || (fun.Recv != nil && fun.Recv.List[0].Type.(*ast.Ident).Name != structName) {
+		if !ok || fun.Recv == nil || len(fun.Recv.List) == 0 {
 			continue
 		}
-		var docs []string
-		if fun.Doc != nil {
-			docs = getComment(fun.Doc)
-		}
 		funcName := fun.Name.Name
+		// 泛型类型的方法, 参数中可引用接收者的类型参数
+		s.setTypeParams(recvTypeParams(fun.Recv.List[0].Type))
 		var paramsName []FuncParam
 		params := fun.Type.Params.List
 		resultCount := 0
@@ -122,31 +325,126 @@ func getStructFuncDoc(structName string, f *ast.File) (result []StructFunc) {
 			resultCount = len(fun.Type.Results.List)
 		}
 		for _, v := range params {
-			var ptype string
-			if pt, ok := v.Type.(*ast.StarExpr); ok {
-				ptReal := pt.X.(*ast.SelectorExpr)
-				ptype = ptReal.X.(*ast.Ident).Name + "." + ptReal.Sel.Name
+			docs, comments := paramComments(v, cmap)
+			if len(v.Names) == 0 {
+				paramsName = append(paramsName, FuncParam{Type: s.typeOf(v.Type), Doc: docs, Comment: comments})
+				continue
 			}
-			if pt, ok := v.Type.(*ast.Ident); ok {
-				ptype = pt.Name
+			for _, n := range v.Names {
+				paramsName = append(paramsName, FuncParam{
+					Name:    n.Name,
+					Type:    s.typeOf(v.Type),
+					Doc:     docs,
+					Comment: comments,
+				})
 			}
-			paramsName = append(paramsName, FuncParam{
-				Name: v.Names[0].Name,
-				Type: ptype,
-			})
 		}
 
+		results := s.getFieldList(fun.Type.Results)
+		s.setTypeParams(nil)
+
 		result = append(result, StructFunc{
+			Recv:        recvName(fun.Recv.List[0].Type),
 			Name:        funcName,
-			Doc:         docs,
+			Pos:         s.position(fun.Pos(), fun.Doc),
+			Doc:         s.getDoc(fun.Doc),
 			Params:      paramsName,
 			ResultCount: resultCount,
+			Results:     results,
 		})
 	}
 
 	return result
 }
 
+// paramComments 参数的注释, go/parser 不会为函数参数填充 Doc 与 Comment, 需从注释映射中查找
+func paramComments(field *ast.Field, cmap ast.CommentMap) (docs []string, comments []string) {
+	for _, g := range cmap[field] {
+		if g.End() < field.Pos() {
+			docs = append(docs, getComment(g)...)
+		} else {
+			comments = append(comments, getComment(g)...)
+		}
+	}
+	return docs, comments
+}
+
+// recvName 方法接收者的类型名, 支持 (u User), (u *User) 与 (p *Page[T])
+func recvName(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		return t.Name
+	case *ast.StarExpr:
+		return recvName(t.X)
+	case *ast.ParenExpr:
+		return recvName(t.X)
+	case *ast.IndexExpr:
+		return recvName(t.X)
+	case *ast.IndexListExpr:
+		return recvName(t.X)
+	}
+	return ""
+}
+
+// recvTypeParams 泛型接收者声明的类型参数名, 如 (p *Page[T]) 中的 T
+func recvTypeParams(expr ast.Expr) (names []string) {
+	var indices []ast.Expr
+	switch t := expr.(type) {
+	case *ast.StarExpr:
+		return recvTypeParams(t.X)
+	case *ast.ParenExpr:
+		return recvTypeParams(t.X)
+	case *ast.IndexExpr:
+		indices = []ast.Expr{t.Index}
+	case *ast.IndexListExpr:
+		indices = t.Indices
+	}
+	for _, v := range indices {
+		if ident, ok := v.(*ast.Ident); ok {
+			names = append(names, ident.Name)
+		}
+	}
+	return names
+}
+
+// promoteFields 将匿名嵌入结构体的字段提升到外层, 同名时外层字段优先;
+// 嵌入字段带有 json 名称, 或无法找到其定义时保持原样
+func promoteFields(fields []StructField, lookup func(t *Type) (StructInfo, bool), seen map[string]bool) (result []StructField) {
+	own := make(map[string]bool)
+	for _, f := range fields {
+		if !f.Embedded {
+			own[f.Name] = true
+		}
+	}
+	for _, f := range fields {
+		if !f.Embedded || jsonName(f) != "" {
+			result = append(result, f)
+			continue
+		}
+		embed, ok := lookup(f.Type)
+		if !ok || seen[embed.FullName()] {
+			result = append(result, f)
+			continue
+		}
+		seen[embed.FullName()] = true
+		for _, pf := range promoteFields(embed.Fields, lookup, seen) {
+			if !own[pf.Name] {
+				result = append(result, pf)
+			}
+		}
+		delete(seen, embed.FullName())
+	}
+	return result
+}
+
+func jsonName(f StructField) string {
+	name := strings.Split(f.Tags["json"], ",")[0]
+	if name == "-" {
+		return ""
+	}
+	return name
+}
+
 func getType(v interface{}) (ptype string) {
 	if pt, ok := v.(*ast.SelectorExpr); ok {
 		ptype = pt.X.(*ast.Ident).Name + "." + pt.Sel.Name
@@ -166,34 +464,40 @@ func getVarsInfo(f *ast.File) (vars []VarInfo) {
 		if obj.Tok.String() != "var" {
 			continue
 		}
-		if len(obj.Specs) == 0 {
-			continue
-		}
-		body, ok := obj.Specs[0].(*ast.ValueSpec)
-		if !ok {
-			continue
-		}
-		var varType string
-		if body.Values == nil {
-			varType = body.Type.(*ast.Ident).Name
-		} else {
-			varType = getType(body.Values[0].(*ast.CompositeLit).Type)
+		for _, spec := range obj.Specs {
+			body, ok := spec.(*ast.ValueSpec)
+			if !ok {
+				continue
+			}
+			for i, name := range body.Names {
+				var varType string
+				if body.Type != nil {
+					varType = getType(body.Type)
+				} else if i < len(body.Values) {
+					if lit, ok := body.Values[i].(*ast.CompositeLit); ok {
+						varType = getType(lit.Type)
+					}
+				}
+				vars = append(vars, VarInfo{
+					Name: name.Name,
+					Type: varType,
+				})
+			}
 		}
-		vars = append(vars, VarInfo{
-			Name: body.Names[0].Name,
-			Type: varType,
-		})
 	}
 	return vars
 }
 
-func getFuncInfo(f *ast.File) (result []FuncInfo) {
+func (s *fileScope) getFuncInfo(f *ast.File) (result []FuncInfo) {
 	for _, item := range f.Decls {
 		obj, ok := item.(*ast.FuncDecl)
 		if ok {
-			result = append(result, FuncInfo{
+			info := FuncInfo{
 				Name: obj.Name.Name,
-			})
+				Pos:  s.position(obj.Pos(), obj.Doc),
+				Doc:  s.getDoc(obj.Doc),
+			}
+			result = append(result, info)
 		}
 	}
 	return
@@ -205,7 +509,32 @@ func FileStructInfo(fileName string) (result []StructInfo, err error) {
 	if err != nil {
 		return result, err
 	}
-	return getStruct(f), nil
+	scope := newFileScope(fset, f)
+	result = scope.getStruct(f)
+	// 结构体的方法只取本文件中声明的
+	methods := scope.getMethods(f)
+	for i := range result {
+		for _, m := range methods {
+			if m.Recv == result[i].Name {
+				result[i].Funcs = append(result[i].Funcs, m)
+			}
+		}
+	}
+	local := make(map[string]StructInfo)
+	for _, v := range result {
+		local[v.Name] = v
+	}
+	lookup := func(t *Type) (si StructInfo, ok bool) {
+		if t = t.Deref(); t == nil || t.Kind != KindNamed || t.Pkg != "" {
+			return si, false
+		}
+		si, ok = local[t.Name]
+		return si, ok
+	}
+	for i := range result {
+		result[i].Fields = promoteFields(result[i].Fields, lookup, make(map[string]bool))
+	}
+	return result, nil
 }
 
 func FileVarInfo(fileName string) (result []VarInfo, err error) {
@@ -223,5 +552,5 @@ func FileFunInfo(fileName string) (result []FuncInfo, err error) {
 	if err != nil {
 		return result, err
 	}
-	return getFuncInfo(f), nil
+	return newFileScope(fset, f).getFuncInfo(f), nil
 }
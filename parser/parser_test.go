@@ -0,0 +1,72 @@
+package parser
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTag(t *testing.T) {
+	tests := []struct {
+		name string
+		tag  string
+		want map[string]string
+	}{
+		{
+			name: "empty",
+			tag:  "",
+			want: map[string]string{},
+		},
+		{
+			name: "multiple keys",
+			tag:  `json:"name,omitempty" form:"name" binding:"required,max=10"`,
+			want: map[string]string{"json": "name,omitempty", "form": "name", "binding": "required,max=10"},
+		},
+		{
+			name: "spaces in value",
+			tag:  `binding:"oneof='a b' c" description:"用户 名称"`,
+			want: map[string]string{"binding": "oneof='a b' c", "description": "用户 名称"},
+		},
+		{
+			name: "escaped quote",
+			tag:  `example:"say \"hi\"" json:"a"`,
+			want: map[string]string{"example": `say "hi"`, "json": "a"},
+		},
+		{
+			name: "extra spaces",
+			tag:  `  json:"a"   form:"b"  `,
+			want: map[string]string{"json": "a", "form": "b"},
+		},
+		{
+			name: "first key wins",
+			tag:  `json:"a" json:"b"`,
+			want: map[string]string{"json": "a"},
+		},
+		{
+			name: "empty value",
+			tag:  `json:"" form:"b"`,
+			want: map[string]string{"json": "", "form": "b"},
+		},
+		{
+			name: "stop at missing quote",
+			tag:  `json:"a" form:b binding:"required"`,
+			want: map[string]string{"json": "a"},
+		},
+		{
+			name: "stop at unterminated value",
+			tag:  `json:"a" form:"b`,
+			want: map[string]string{"json": "a"},
+		},
+		{
+			name: "stop at invalid escape",
+			tag:  `json:"a" form:"\q" binding:"required"`,
+			want: map[string]string{"json": "a"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseTag(tt.tag); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseTag(%q) = %v, want %v", tt.tag, got, tt.want)
+			}
+		})
+	}
+}
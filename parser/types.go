@@ -0,0 +1,335 @@
+package parser
+
+import (
+	"go/ast"
+	"go/token"
+	"go/types"
+	"strconv"
+	"strings"
+)
+
+type Kind string
+
+const (
+	KindBasic     Kind = "basic"
+	KindNamed     Kind = "named"
+	KindPointer   Kind = "pointer"
+	KindSlice     Kind = "slice"
+	KindArray     Kind = "array"
+	KindMap       Kind = "map"
+	KindStruct    Kind = "struct"
+	KindChan      Kind = "chan"
+	KindInterface Kind = "interface"
+	KindFunc      Kind = "func"
+	// KindParam 泛型声明中的类型参数, 如 Page[T] 中的 T
+	KindParam Kind = "param"
+)
+
+// Type 字段/参数的类型描述
+type Type struct {
+	Kind Kind
+	// Name 基础类型或命名类型的名称, 如 int, User
+	Name string
+	// Pkg 源码中引用的包名, 如 time.Time 中的 time
+	Pkg string
+	// PkgPath 包的导入路径
+	PkgPath string
+	// Elem 指针, 切片, 数组, map, chan 的元素类型
+	Elem *Type
+	// Key map 的键类型
+	Key *Type
+	// Len 数组长度表达式
+	Len string
+	// Dir chan 的方向
+	Dir ast.ChanDir
+	// Fields 匿名结构体的字段
+	Fields []StructField
+	// Args 泛型实例化的类型实参, 如 Page[User] 中的 User
+	Args []*Type
+}
+
+func (t *Type) String() string {
+	if t == nil {
+		return ""
+	}
+	switch t.Kind {
+	case KindBasic:
+		return t.Name
+	case KindNamed:
+		name := t.Name
+		if t.Pkg != "" {
+			name = t.Pkg + "." + t.Name
+		}
+		if len(t.Args) > 0 {
+			args := make([]string, len(t.Args))
+			for i, a := range t.Args {
+				args[i] = a.String()
+			}
+			name += "[" + strings.Join(args, ", ") + "]"
+		}
+		return name
+	case KindPointer:
+		return "*" + t.Elem.String()
+	case KindSlice:
+		return "[]" + t.Elem.String()
+	case KindArray:
+		return "[" + t.Len + "]" + t.Elem.String()
+	case KindMap:
+		return "map[" + t.Key.String() + "]" + t.Elem.String()
+	case KindChan:
+		switch t.Dir {
+		case ast.SEND:
+			return "chan<- " + t.Elem.String()
+		case ast.RECV:
+			return "<-chan " + t.Elem.String()
+		}
+		return "chan " + t.Elem.String()
+	case KindStruct:
+		return "struct{...}"
+	case KindInterface:
+		return "interface{}"
+	case KindFunc:
+		return "func"
+	}
+	return t.Name
+}
+
+// Deref 去掉指针, 返回实际指向的类型
+func (t *Type) Deref() *Type {
+	for t != nil && t.Kind == KindPointer {
+		t = t.Elem
+	}
+	return t
+}
+
+// Subst 以类型实参替换类型参数, 返回替换后的副本, 原类型不变
+func (t *Type) Subst(args map[string]*Type) *Type {
+	if t == nil || len(args) == 0 {
+		return t
+	}
+	if t.Kind == KindParam {
+		if a, ok := args[t.Name]; ok {
+			return a
+		}
+		return t
+	}
+	c := *t
+	c.Elem = t.Elem.Subst(args)
+	c.Key = t.Key.Subst(args)
+	if t.Args != nil {
+		c.Args = make([]*Type, len(t.Args))
+		for i, a := range t.Args {
+			c.Args[i] = a.Subst(args)
+		}
+	}
+	if t.Fields != nil {
+		c.Fields = substFields(t.Fields, args)
+	}
+	return &c
+}
+
+func substFields(fields []StructField, args map[string]*Type) []StructField {
+	result := make([]StructField, len(fields))
+	for i, f := range fields {
+		f.Type = f.Type.Subst(args)
+		result[i] = f
+	}
+	return result
+}
+
+// fileScope 单个文件的类型解析上下文
+type fileScope struct {
+	fset *token.FileSet
+	// file 文件路径
+	file string
+	// pkgPath 文件所在包的导入路径, 单文件解析时为空
+	pkgPath string
+	// imports 包名 => 导入路径
+	imports map[string]string
+	// info 类型检查结果, 单文件解析时为 nil
+	info *types.Info
+	// typeParams 正在解析的泛型声明的类型参数
+	typeParams map[string]bool
+}
+
+func newFileScope(fset *token.FileSet, f *ast.File) *fileScope {
+	s := &fileScope{
+		fset:    fset,
+		file:    fset.File(f.Pos()).Name(),
+		imports: make(map[string]string),
+	}
+	for _, spec := range f.Imports {
+		path, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			continue
+		}
+		name := importName(path)
+		if spec.Name != nil {
+			name = spec.Name.Name
+		}
+		if name == "_" || name == "." {
+			continue
+		}
+		s.imports[name] = path
+	}
+	return s
+}
+
+// newPackageScope 带类型信息的文件上下文, 导入包名以类型检查结果为准
+func newPackageScope(fset *token.FileSet, f *ast.File, pkgPath string, info *types.Info) *fileScope {
+	s := newFileScope(fset, f)
+	s.pkgPath = pkgPath
+	s.info = info
+	if info == nil {
+		return s
+	}
+	for _, spec := range f.Imports {
+		var obj types.Object
+		if spec.Name != nil {
+			obj = info.Defs[spec.Name]
+		} else {
+			obj = info.Implicits[spec]
+		}
+		if pn, ok := obj.(*types.PkgName); ok {
+			s.imports[pn.Name()] = pn.Imported().Path()
+		}
+	}
+	return s
+}
+
+// importName 推断未显式命名的导入包名, 处理 /v2 及 gopkg.in/yaml.v2 这类路径
+func importName(path string) string {
+	parts := strings.Split(path, "/")
+	name := parts[len(parts)-1]
+	if len(parts) > 1 && len(name) > 1 && name[0] == 'v' {
+		if _, err := strconv.Atoi(name[1:]); err == nil {
+			name = parts[len(parts)-2]
+		}
+	}
+	if i := strings.Index(name, ".v"); i > 0 {
+		name = name[:i]
+	}
+	return strings.Replace(name, "-", "_", -1)
+}
+
+func (s *fileScope) typeOf(expr ast.Expr) *Type {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		if s.typeParams[t.Name] {
+			return &Type{Kind: KindParam, Name: t.Name}
+		}
+		if isBasic(t.Name) {
+			return &Type{Kind: KindBasic, Name: t.Name}
+		}
+		if t.Name == "any" {
+			return &Type{Kind: KindInterface}
+		}
+		// error, comparable 等预声明类型不属于当前包
+		if _, ok := types.Universe.Lookup(t.Name).(*types.TypeName); ok {
+			return &Type{Kind: KindNamed, Name: t.Name}
+		}
+		return &Type{Kind: KindNamed, Name: t.Name, PkgPath: s.pkgPath}
+	case *ast.SelectorExpr:
+		pkg := ""
+		if x, ok := t.X.(*ast.Ident); ok {
+			pkg = x.Name
+		}
+		return &Type{Kind: KindNamed, Name: t.Sel.Name, Pkg: pkg, PkgPath: s.importPath(t.X)}
+	case *ast.IndexExpr:
+		c := *s.typeOf(t.X)
+		c.Args = []*Type{s.typeOf(t.Index)}
+		return &c
+	case *ast.IndexListExpr:
+		c := *s.typeOf(t.X)
+		c.Args = nil
+		for _, v := range t.Indices {
+			c.Args = append(c.Args, s.typeOf(v))
+		}
+		return &c
+	case *ast.StarExpr:
+		return &Type{Kind: KindPointer, Elem: s.typeOf(t.X)}
+	case *ast.ParenExpr:
+		return s.typeOf(t.X)
+	case *ast.ArrayType:
+		if t.Len == nil {
+			return &Type{Kind: KindSlice, Elem: s.typeOf(t.Elt)}
+		}
+		return &Type{Kind: KindArray, Len: exprString(t.Len), Elem: s.typeOf(t.Elt)}
+	case *ast.Ellipsis:
+		return &Type{Kind: KindSlice, Elem: s.typeOf(t.Elt)}
+	case *ast.MapType:
+		return &Type{Kind: KindMap, Key: s.typeOf(t.Key), Elem: s.typeOf(t.Value)}
+	case *ast.ChanType:
+		return &Type{Kind: KindChan, Dir: t.Dir, Elem: s.typeOf(t.Value)}
+	case *ast.StructType:
+		return &Type{Kind: KindStruct, Fields: s.getStructFieldTag(t.Fields.List)}
+	case *ast.InterfaceType:
+		return &Type{Kind: KindInterface}
+	case *ast.FuncType:
+		return &Type{Kind: KindFunc}
+	}
+	return &Type{Name: exprString(expr)}
+}
+
+// setTypeParams 设置当前泛型声明的类型参数, names 为空时清除
+func (s *fileScope) setTypeParams(names []string) {
+	s.typeParams = nil
+	if len(names) == 0 {
+		return
+	}
+	s.typeParams = make(map[string]bool, len(names))
+	for _, n := range names {
+		s.typeParams[n] = true
+	}
+}
+
+// typeParamNames 类型参数列表中的参数名, 如 [K comparable, V any] 中的 K, V
+func typeParamNames(list *ast.FieldList) (names []string) {
+	if list == nil {
+		return names
+	}
+	for _, v := range list.List {
+		for _, n := range v.Names {
+			names = append(names, n.Name)
+		}
+	}
+	return names
+}
+
+// importPath 解析选择器表达式中包名对应的导入路径, 有类型信息时以类型信息为准
+func (s *fileScope) importPath(x ast.Expr) string {
+	ident, ok := x.(*ast.Ident)
+	if !ok {
+		return ""
+	}
+	if s.info != nil {
+		if pn, ok := s.info.Uses[ident].(*types.PkgName); ok {
+			return pn.Imported().Path()
+		}
+	}
+	return s.imports[ident.Name]
+}
+
+// position 节点在源文件中的位置, 节点带有文档注释时取注释的起始位置
+func (s *fileScope) position(pos token.Pos, doc *ast.CommentGroup) token.Position {
+	if s.fset == nil {
+		return token.Position{Filename: s.file}
+	}
+	if doc != nil {
+		pos = doc.Pos()
+	}
+	return s.fset.Position(pos)
+}
+
+func isBasic(name string) bool {
+	obj, ok := types.Universe.Lookup(name).(*types.TypeName)
+	if !ok {
+		return false
+	}
+	_, ok = obj.Type().(*types.Basic)
+	return ok
+}
+
+func exprString(expr ast.Expr) string {
+	return types.ExprString(expr)
+}
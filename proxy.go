@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/daodao97/egin-tools/swagger"
+)
+
+// proxyHeaders -proxy-header 可重复出现, 每次添加一个转发时注入的请求头
+type proxyHeaders []string
+
+func (h *proxyHeaders) String() string {
+	return strings.Join(*h, ", ")
+}
+
+func (h *proxyHeaders) Set(v string) error {
+	i := strings.Index(v, ":")
+	if i <= 0 {
+		return errors.Errorf("invalid header %q, want Name: value", v)
+	}
+	*h = append(*h, v)
+	return nil
+}
+
+// newProxy 将接口请求转发到 target, 注入配置的请求头并记录每个请求的响应状态与耗时
+func newProxy(target string, headers []string) (http.Handler, error) {
+	u, err := url.Parse(target)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return nil, errors.Errorf("invalid proxy target %q, want http://host:port", target)
+	}
+	proxy := httputil.NewSingleHostReverseProxy(u)
+	director := proxy.Director
+	proxy.Director = func(req *http.Request) {
+		director(req)
+		req.Host = u.Host
+		for _, v := range headers {
+			i := strings.Index(v, ":")
+			req.Header.Set(strings.TrimSpace(v[:i]), strings.TrimSpace(v[i+1:]))
+		}
+	}
+	proxy.ErrorHandler = func(w http.ResponseWriter, req *http.Request, err error) {
+		fmt.Printf("[proxy] %s %s error: %s\n", req.Method, req.URL.RequestURI(), err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		uri := req.URL.RequestURI()
+		proxy.ServeHTTP(rec, req)
+		fmt.Printf("[proxy] %s %s -> %d %s\n", req.Method, uri, rec.status, time.Since(start).Round(time.Millisecond))
+	}), nil
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// proxied 文档中的服务地址改为文档页面的来源, Try it out 的请求由 ui 服务转发, 无需处理跨域
+func proxied(doc interface{}) interface{} {
+	switch d := doc.(type) {
+	case *swagger.Swagger:
+		copied := *d
+		copied.Host, copied.Schemes = "", []string{}
+		return &copied
+	case *swagger.OpenAPI:
+		copied := *d
+		path := "/"
+		if len(d.Servers) > 0 {
+			if u, err := url.Parse(d.Servers[0].Url); err == nil && strings.TrimSuffix(u.Path, "/") != "" {
+				path = strings.TrimSuffix(u.Path, "/")
+			}
+		}
+		copied.Servers = []swagger.Server{{Url: path, Description: "由文档服务转发到 " + *proxyTarget}}
+		return &copied
+	}
+	return doc
+}
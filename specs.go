@@ -0,0 +1,124 @@
+package main
+
+import (
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/daodao97/egin-tools/swagger"
+)
+
+// mergedName 合并后的文档名, 开启 -merge 时在 /specs/all.json 提供
+const mergedName = "all"
+
+var specName = regexp.MustCompile(`^[\w-]+$`)
+
+// specDocs 由 -spec 加载的其他服务的文档
+var specDocs []swagger.Service
+
+type specSource struct {
+	Name string
+	Path string
+}
+
+// specSources -spec 可重复出现, 每次添加一个服务的文档, 格式为 name=path
+type specSources []specSource
+
+func (s *specSources) String() string {
+	var list []string
+	for _, v := range *s {
+		list = append(list, v.Name+"="+v.Path)
+	}
+	return strings.Join(list, ", ")
+}
+
+func (s *specSources) Set(v string) error {
+	i := strings.Index(v, "=")
+	if i <= 0 || i == len(v)-1 {
+		return errors.Errorf("invalid spec %q, want name=path", v)
+	}
+	name := v[:i]
+	if !specName.MatchString(name) {
+		return errors.Errorf("invalid spec name %q, want letters, digits, _ or -", name)
+	}
+	if name == mergedName {
+		return errors.Errorf("spec name %s is reserved for the merged spec", name)
+	}
+	for _, exist := range *s {
+		if exist.Name == name {
+			return errors.Errorf("duplicate spec name %s", name)
+		}
+	}
+	*s = append(*s, specSource{Name: name, Path: v[i+1:]})
+	return nil
+}
+
+// loadSpecs 加载 -spec 指定的文档
+func loadSpecs() {
+	for _, s := range specList {
+		doc, err := loadSpec(s.Path)
+		onErr(errors.Wrapf(err, "spec %s", s.Name))
+		specDocs = append(specDocs, swagger.Service{Name: s.Name, Doc: doc})
+	}
+}
+
+// loadSpec 路径为目录时作为项目根目录解析并生成文档, 否则作为已生成的文档文件读取
+func loadSpec(path string) (interface{}, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return swagger.Read(path)
+	}
+	p, err := loadProgram(path)
+	if err != nil {
+		return nil, err
+	}
+	return buildSwagger(p)
+}
+
+// services ui 中展示的所有文档, 当前项目排在最前, 监听模式下其文档会更新
+func services(project string) []swagger.Service {
+	var list []swagger.Service
+	if *genDoc {
+		list = append(list, swagger.Service{Name: project, Doc: getApidoc()})
+	}
+	return append(list, specDocs...)
+}
+
+// specDoc /specs/{name}.json 对应的文档, 不存在时返回 nil
+func specDoc(name string, list []swagger.Service) (interface{}, error) {
+	if name == mergedName && *merge {
+		return swagger.Merge(list)
+	}
+	for _, s := range list {
+		if s.Name == name {
+			return s.Doc, nil
+		}
+	}
+	return nil, nil
+}
+
+// specUrl Swagger UI 的 urls 配置, 页面顶部的下拉框用于切换文档
+type specUrl struct {
+	Name string `json:"name"`
+	Url  string `json:"url"`
+}
+
+// specUrls 只有一个文档且不合并时返回 nil, 页面直接加载 ./swagger.json
+func specUrls(list []swagger.Service) []specUrl {
+	if len(list) < 2 && !*merge {
+		return nil
+	}
+	var urls []specUrl
+	for _, s := range list {
+		urls = append(urls, specUrl{Name: s.Name, Url: "./specs/" + s.Name + ".json"})
+	}
+	if *merge {
+		urls = append(urls, specUrl{Name: mergedName, Url: "./specs/" + mergedName + ".json"})
+	}
+	return urls
+}
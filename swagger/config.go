@@ -0,0 +1,186 @@
+package swagger
+
+import (
+	"io/ioutil"
+	"os"
+	"regexp"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+
+	"github.com/daodao97/egin-tools/annotation"
+	"github.com/daodao97/egin-tools/diag"
+	"github.com/daodao97/egin-tools/parser"
+)
+
+// DefaultConfigFile 项目根目录下的文档配置文件
+const DefaultConfigFile = "egin-tools.yaml"
+
+// Config 文档配置, 如
+//
+//	info:
+//	  title: 用户中心
+//	  version: 1.0.0
+//	host: api.example.com
+//	basePath: /api
+//	schemes: [https]
+//	servers:
+//	  - url: http://localhost:8080
+//	    env: dev
+//	  - url: https://api.example.com
+//	    description: 生产环境
+//	    env: prod
+//	patterns:
+//	  mobile: ^1[3-9]\d{9}$
+//	securitySchemes:
+//	  jwt:
+//	    type: bearer
+//	    bearerFormat: JWT
+//	middlewareSecurity:
+//	  JwtAuth: jwt
+type Config struct {
+	Info     Info        `yaml:"info"`
+	Host     string      `yaml:"host"`
+	BasePath string      `yaml:"basePath"`
+	Schemes  []string    `yaml:"schemes"`
+	Servers  []EnvServer `yaml:"servers"`
+	// Patterns 自定义校验规则 => 正则, 见 Patterns
+	Patterns map[string]string `yaml:"patterns"`
+	// SecuritySchemes 安全方案, 见 SecurityScheme
+	SecuritySchemes map[string]*SecurityScheme `yaml:"securitySchemes"`
+	// MiddlewareSecurity 中间件名 => 安全方案名, 使用该中间件的接口需要对应的认证
+	MiddlewareSecurity map[string]string `yaml:"middlewareSecurity"`
+}
+
+// EnvServer 指定环境的服务地址, Env 为空时适用于所有环境
+type EnvServer struct {
+	Server `yaml:",inline"`
+	Env    string `yaml:"env,omitempty"`
+}
+
+// LoadConfig 读取配置文件, 文件不存在时返回 nil
+func LoadConfig(file string) (*Config, error) {
+	content, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "read %s", file)
+	}
+	c := &Config{}
+	if err := yaml.UnmarshalStrict(content, c); err != nil {
+		return nil, errors.Wrapf(err, "parse %s", file)
+	}
+	for name, pattern := range c.Patterns {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return nil, errors.Wrapf(err, "%s: invalid pattern %s", file, name)
+		}
+	}
+	for name, scheme := range c.SecuritySchemes {
+		if err := scheme.validate(); err != nil {
+			return nil, errors.Wrapf(err, "%s: security scheme %s", file, name)
+		}
+	}
+	for middleware, name := range c.MiddlewareSecurity {
+		if _, ok := c.SecuritySchemes[name]; !ok {
+			return nil, errors.Errorf("%s: middleware %s: security scheme %s not defined", file, middleware, name)
+		}
+	}
+	return c, nil
+}
+
+// ApplyConfig 以配置文件中的非空项覆盖文档信息, env 不为空时只保留该环境的服务地址
+func (s *Spec) ApplyConfig(c *Config, env string) {
+	if c == nil {
+		return
+	}
+	s.setInfo(c.Info)
+	var servers []Server
+	for _, v := range c.Servers {
+		if server, ok := envServer(v.Server, v.Env, env); ok {
+			servers = append(servers, server)
+		}
+	}
+	s.setLocation(c.Host, c.BasePath, c.Schemes, servers)
+	for name, pattern := range c.Patterns {
+		s.Patterns[name] = pattern
+	}
+	for name, scheme := range c.SecuritySchemes {
+		s.SecuritySchemes[name] = scheme
+	}
+	for middleware, name := range c.MiddlewareSecurity {
+		s.MiddlewareSecurity[middleware] = name
+	}
+}
+
+// General 以 main 函数上的注解设置文档信息, 模块中有多个 main 函数时取第一个带注释的
+func General(spec *Spec, prog *parser.Program, env string) {
+	for _, f := range prog.Files {
+		for _, fn := range f.Funcs {
+			if fn.Name != "main" || fn.Doc == nil {
+				continue
+			}
+			g, err := annotation.ParseGeneral(fn.Doc)
+			diag.Report(diag.Error, diag.MalformedAnnotation, err)
+			spec.ApplyGeneral(g, env)
+			return
+		}
+	}
+}
+
+// ApplyGeneral 以注解中的非空项覆盖文档信息, env 不为空时只保留该环境的服务地址
+func (s *Spec) ApplyGeneral(g *annotation.General, env string) {
+	info := Info{Title: g.Title, Version: g.Version, Description: g.Desc, TermsOfService: g.TermsOfService}
+	if g.Contact != nil {
+		info.Contact = &Contact{Name: g.Contact.Name, Url: g.Contact.Url, Email: g.Contact.Email}
+	}
+	if g.License != nil {
+		info.License = &License{Name: g.License.Name, Url: g.License.Url}
+	}
+	s.setInfo(info)
+	var servers []Server
+	for _, v := range g.Servers {
+		if server, ok := envServer(Server{Url: v.Url, Description: v.Desc}, v.Env, env); ok {
+			servers = append(servers, server)
+		}
+	}
+	s.setLocation(g.Host, g.BasePath, g.Schemes, servers)
+}
+
+func (s *Spec) setInfo(info Info) {
+	set := func(dst *string, src string) {
+		if src != "" {
+			*dst = src
+		}
+	}
+	set(&s.Info.Title, info.Title)
+	set(&s.Info.Description, info.Description)
+	set(&s.Info.Version, info.Version)
+	set(&s.Info.TermsOfService, info.TermsOfService)
+	if info.Contact != nil {
+		s.Info.Contact = info.Contact
+	}
+	if info.License != nil {
+		s.Info.License = info.License
+	}
+}
+
+// setLocation 服务地址由后设置的来源整体决定, 配置文件中声明了 host, basePath, schemes 或 servers 中的任意一项时,
+// 注解中的服务地址全部忽略, 避免两个来源的地址混在一起
+func (s *Spec) setLocation(host string, basePath string, schemes []string, servers []Server) {
+	if host == "" && basePath == "" && len(schemes) == 0 && len(servers) == 0 {
+		return
+	}
+	s.Host, s.BasePath, s.Schemes, s.Servers = host, basePath, schemes, servers
+}
+
+// envServer 服务地址是否属于环境 env, 未指定 env 时保留所有环境, 并以环境名作为缺省的说明
+func envServer(server Server, serverEnv string, env string) (Server, bool) {
+	if env != "" && serverEnv != "" && serverEnv != env {
+		return server, false
+	}
+	if server.Description == "" {
+		server.Description = serverEnv
+	}
+	return server, true
+}
@@ -0,0 +1,210 @@
+package swagger
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/daodao97/egin-tools/annotation"
+	"github.com/daodao97/egin-tools/diag"
+	"github.com/daodao97/egin-tools/parser"
+)
+
+// fieldSchema 字段的结构, 带有字段说明与枚举取值
+func (b *builder) fieldSchema(field parser.StructField) *Schema {
+	schema := b.schemaOf(field.Type)
+	if schema == nil {
+		diag.Warnf(field.Pos, diag.UnsupportedParamType, "field %s: unsupported type %s", field.Name, field.Type)
+		schema = &Schema{}
+	}
+	schema.Description = field.Description()
+	if consts := b.prog.Enum(field.Type.Deref()); len(consts) > 0 {
+		schema.Enum, schema.Description = transEnum(consts, schema.Description)
+	}
+	applyRules(schema, strings.Split(field.Tags["binding"], ","), b.spec.Patterns)
+	return schema
+}
+
+// transEnum 枚举类型的取值列表, 常量名与注释追加到描述中
+func transEnum(consts []parser.ConstInfo, desc string) (enum []interface{}, description string) {
+	lines := []string{}
+	if desc != "" {
+		lines = append(lines, desc)
+	}
+	for _, c := range consts {
+		enum = append(enum, c.Literal())
+		line := c.Name + "=" + c.Value
+		comment := append(append([]string{}, c.Doc...), c.Comment...)
+		if len(comment) > 0 {
+			line += " " + strings.TrimPrefix(strings.Join(comment, " "), c.Name+" ")
+		}
+		lines = append(lines, line)
+	}
+	return enum, strings.Join(lines, "\n")
+}
+
+// schemaOf 将 go 类型转为 Schema, 无法映射时返回 nil, 结构体以 $ref 引用其定义
+func (b *builder) schemaOf(t *parser.Type) *Schema {
+	if t == nil {
+		return nil
+	}
+	if t.Kind == parser.KindPointer {
+		schema := b.schemaOf(t.Elem)
+		if schema != nil {
+			schema.Nullable = true
+		}
+		return schema
+	}
+	typ, format := transType(t, b.prog)
+	switch typ {
+	case "":
+		return nil
+	case "array":
+		return &Schema{Type: typ, Items: orAny(b.schemaOf(underlying(t, b.prog).Elem))}
+	case "object":
+		return b.objectSchema(t)
+	}
+	return &Schema{Type: typ, Format: format}
+}
+
+// objectSchema 结构体, map, 接口对应的对象结构
+func (b *builder) objectSchema(t *parser.Type) *Schema {
+	u := underlying(t, b.prog)
+	switch u.Kind {
+	case parser.KindMap:
+		return &Schema{Type: "object", AdditionalProperties: orAny(b.schemaOf(u.Elem))}
+	case parser.KindStruct:
+		return b.structSchema(u.Fields)
+	case parser.KindInterface:
+		return &Schema{}
+	}
+	if si, ok := b.prog.Lookup(u); ok {
+		return b.definition(si)
+	}
+	// 接口的取值为包内实现了该接口的结构体之一
+	if impls := b.prog.Implementations(u); len(impls) > 0 {
+		schema := &Schema{}
+		for _, si := range impls {
+			schema.OneOf = append(schema.OneOf, b.definition(si))
+		}
+		return schema
+	}
+	if _, ok := b.prog.Interface(u); ok {
+		return &Schema{}
+	}
+	return &Schema{Type: "object"}
+}
+
+// definition 将结构体注册为定义并返回对它的引用.
+// 先登记名称再展开字段, 结构体直接或间接引用自身时只生成引用, 不会无限递归
+func (b *builder) definition(si parser.StructInfo) *Schema {
+	key := si.FullName()
+	if name, ok := b.spec.defs[key]; ok {
+		return &Schema{Ref: name}
+	}
+	name := b.definitionName(si)
+	b.spec.defs[key] = name
+	b.spec.Schemas[name] = &Schema{Type: "object"}
+	schema := b.structSchema(si.Fields)
+	schema.Description = strings.TrimPrefix(strings.Join(annotation.Strings(si.Doc), " "), si.Name+" ")
+	b.spec.Schemas[name] = schema
+	return &Schema{Ref: name}
+}
+
+// definitionName 定义的名称, 默认为结构体名, 重名时加上包名, 仍重名时加序号.
+// 泛型实例 Page[dto.User] 的名称为 Page_dto_User
+func (b *builder) definitionName(si parser.StructInfo) string {
+	name := strings.NewReplacer("[", "_", "]", "", ", ", "_", ".", "_", "*", "").Replace(si.Name)
+	if _, exist := b.spec.Schemas[name]; !exist {
+		return name
+	}
+	if si.PkgPath != "" {
+		pkg := si.PkgPath[strings.LastIndex(si.PkgPath, "/")+1:]
+		name = pkg + "_" + name
+	}
+	unique := name
+	for i := 2; ; i++ {
+		if _, exist := b.spec.Schemas[unique]; !exist {
+			return unique
+		}
+		unique = name + strconv.Itoa(i)
+	}
+}
+
+func (b *builder) structSchema(fields []parser.StructField) *Schema {
+	schema := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	for _, f := range fields {
+		if !isProperty(f) {
+			continue
+		}
+		name := propertyName(f)
+		schema.Properties[name] = b.fieldSchema(f)
+		if isRequired(f) {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+	return schema
+}
+
+// orAny 无法映射的元素类型按任意类型处理
+func orAny(s *Schema) *Schema {
+	if s == nil {
+		return &Schema{}
+	}
+	return s
+}
+
+// underlying 去掉指针并展开非结构体的命名类型, 如 type IDs []int 返回 []int
+func underlying(t *parser.Type, prog *parser.Program) *parser.Type {
+	t = t.Deref()
+	// 限制展开次数, 避免非法的循环定义导致死循环
+	for i := 0; i < 10; i++ {
+		named, ok := prog.NamedType(t)
+		if !ok {
+			break
+		}
+		t = named.Type.Deref()
+	}
+	return t
+}
+
+// transType 将 go 类型映射为 swagger 的 type/format, 非结构体的命名类型按其底层类型映射
+func transType(t *parser.Type, prog *parser.Program) (typ string, format string) {
+	t = t.Deref()
+	if t == nil {
+		return "", ""
+	}
+	switch t.Kind {
+	case parser.KindBasic:
+		switch t.Name {
+		case "bool":
+			return "boolean", ""
+		case "string":
+			return "string", ""
+		case "int8", "int16", "int32", "uint8", "uint16", "uint32", "byte", "rune":
+			return "integer", "int32"
+		case "int", "uint", "int64", "uint64", "uintptr":
+			// int, uint, uintptr 在支持的平台上均为 64 位
+			return "integer", "int64"
+		case "float32":
+			return "number", "float"
+		case "float64":
+			return "number", "double"
+		}
+	case parser.KindNamed:
+		if t.PkgPath == "time" && t.Name == "Time" {
+			return "string", "date-time"
+		}
+		if t.PkgPath == "time" && t.Name == "Duration" {
+			return "integer", "int64"
+		}
+		if named, ok := prog.NamedType(t); ok {
+			return transType(named.Type, prog)
+		}
+		return "object", ""
+	case parser.KindSlice, parser.KindArray:
+		return "array", ""
+	case parser.KindMap, parser.KindStruct, parser.KindInterface:
+		return "object", ""
+	}
+	return "", ""
+}
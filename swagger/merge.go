@@ -0,0 +1,276 @@
+package swagger
+
+import (
+	"encoding/json"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Service 一个服务的文档, Doc 为生成的 *Swagger, *OpenAPI 或由 Read 读取的文档
+type Service struct {
+	Name string
+	Doc  interface{}
+}
+
+// mergedNote 合并后文档的说明, 提示在页面中调试接口时切换到各服务的文档
+const mergedNote = "合并的文档只用于浏览, 路径带有服务名前缀, 调试接口请切换到对应服务的文档"
+
+// methods 路径下表示操作的键
+var methods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+// Merge 将多个服务的文档合并为一个, 路径以 /服务名 为前缀, 之后为该服务的 basePath 或服务地址中的路径.
+// 合并后的文档只用于浏览, 不含服务地址, 带前缀的路径也无法直接请求. 各文档的版本需一致,
+// 同名但内容不同的 schema, 安全方案等重命名为 服务名.原名, 重复的 operationId 同样加上服务名
+func Merge(services []Service) (map[string]interface{}, error) {
+	if len(services) == 0 {
+		return nil, errors.New("no service to merge")
+	}
+	out := map[string]interface{}{}
+	paths := map[string]interface{}{}
+	sections := map[string]map[string]interface{}{}
+	operationIds := map[string]bool{}
+	var tags []interface{}
+	tagNames := map[string]bool{}
+	var names, desc []string
+
+	for _, s := range services {
+		doc, err := toMap(s.Doc)
+		if err != nil {
+			return nil, errors.Wrapf(err, "service %s", s.Name)
+		}
+		key := versionKey(doc)
+		if key == "" {
+			return nil, errors.Errorf("service %s: not a swagger or openapi document", s.Name)
+		}
+		if len(out) == 0 {
+			out[key] = doc[key]
+		} else if _, ok := out[key]; !ok {
+			return nil, errors.Errorf("service %s: cannot merge swagger 2.0 and openapi 3 documents", s.Name)
+		}
+		names = append(names, s.Name)
+		if info, ok := doc["info"].(map[string]interface{}); ok {
+			desc = append(desc, strings.TrimSpace("- "+s.Name+": "+str(info["title"])+" "+str(info["version"])))
+		}
+
+		// 先确定重命名, 改写引用后再放入合并的文档. 引用了被重命名项的定义改写后内容可能不再相同,
+		// 重复比较直到没有新的重命名
+		own := components(doc, key)
+		renames := map[string]map[string]string{}
+		for changed := true; changed; {
+			changed = false
+			rewriteRefs(doc, renames)
+			for prefix, entries := range own {
+				for name, entry := range entries {
+					if _, ok := renames[prefix][name]; ok {
+						continue
+					}
+					if exist, ok := sections[prefix][name]; ok && !reflect.DeepEqual(exist, entry) {
+						if renames[prefix] == nil {
+							renames[prefix] = map[string]string{}
+						}
+						renames[prefix][name] = s.Name + "." + name
+						changed = true
+					}
+				}
+			}
+		}
+		for prefix, entries := range own {
+			if sections[prefix] == nil {
+				sections[prefix] = map[string]interface{}{}
+			}
+			for name, entry := range entries {
+				if v, ok := renames[prefix][name]; ok {
+					name = v
+				}
+				sections[prefix][name] = entry
+			}
+		}
+
+		security := doc["security"]
+		schemes := renames[securityPrefix(key)]
+		prefix := "/" + s.Name + basePath(doc, key)
+		items, _ := doc["paths"].(map[string]interface{})
+		for _, path := range sortedPaths(items) {
+			item, ok := items[path].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			for _, method := range methods {
+				op, ok := item[method].(map[string]interface{})
+				if !ok {
+					continue
+				}
+				// 服务级的安全要求下放到各个操作, 合并后的文档没有统一的安全要求
+				if _, ok := op["security"]; !ok && security != nil {
+					op["security"] = security
+				}
+				renameSecurity(op["security"], schemes)
+				if id, ok := op["operationId"].(string); ok {
+					if operationIds[id] {
+						id = s.Name + "." + id
+						op["operationId"] = id
+					}
+					operationIds[id] = true
+				}
+			}
+			paths[prefix+path] = item
+		}
+
+		list, _ := doc["tags"].([]interface{})
+		for _, v := range list {
+			tag, _ := v.(map[string]interface{})
+			if name := str(tag["name"]); !tagNames[name] {
+				tagNames[name] = true
+				tags = append(tags, v)
+			}
+		}
+	}
+
+	out["info"] = map[string]interface{}{
+		"title":       strings.Join(names, ", "),
+		"description": strings.Join(append(desc, "", mergedNote), "\n"),
+		"version":     "merged",
+	}
+	out["paths"] = paths
+	if len(tags) > 0 {
+		out["tags"] = tags
+	}
+	for prefix, entries := range sections {
+		if len(entries) > 0 {
+			setIn(out, strings.Split(strings.Trim(prefix, "#/"), "/"), entries)
+		}
+	}
+	return out, nil
+}
+
+// toMap 经由 JSON 复制一份文档, 合并时的改写不影响原文档
+func toMap(doc interface{}) (map[string]interface{}, error) {
+	content, err := json.Marshal(doc)
+	if err != nil {
+		return nil, errors.Wrap(err, "marshal json")
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal(content, &m); err != nil {
+		return nil, errors.Wrap(err, "not a json object")
+	}
+	return m, nil
+}
+
+// versionKey 文档中表示版本的键, Swagger 2.0 为 swagger, OpenAPI 3 为 openapi
+func versionKey(doc map[string]interface{}) string {
+	for _, key := range []string{"swagger", "openapi"} {
+		if _, ok := doc[key]; ok {
+			return key
+		}
+	}
+	return ""
+}
+
+func securityPrefix(key string) string {
+	if key == "swagger" {
+		return "#/securityDefinitions/"
+	}
+	return "#/components/securitySchemes/"
+}
+
+// components 文档中可被引用的各组定义, 以引用的前缀为键, 如 #/definitions/
+func components(doc map[string]interface{}, key string) map[string]map[string]interface{} {
+	result := map[string]map[string]interface{}{}
+	if key == "swagger" {
+		for _, name := range []string{"definitions", "parameters", "responses", "securityDefinitions"} {
+			if m, ok := doc[name].(map[string]interface{}); ok {
+				result["#/"+name+"/"] = m
+			}
+		}
+		return result
+	}
+	all, _ := doc["components"].(map[string]interface{})
+	for name, v := range all {
+		if m, ok := v.(map[string]interface{}); ok {
+			result["#/components/"+name+"/"] = m
+		}
+	}
+	return result
+}
+
+// rewriteRefs 将 $ref 中被重命名的定义改为新名称
+func rewriteRefs(v interface{}, renames map[string]map[string]string) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, item := range v {
+			ref, ok := item.(string)
+			if k != "$ref" || !ok {
+				rewriteRefs(item, renames)
+				continue
+			}
+			for prefix, names := range renames {
+				if name, ok := names[strings.TrimPrefix(ref, prefix)]; ok && strings.HasPrefix(ref, prefix) {
+					v[k] = prefix + name
+				}
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			rewriteRefs(item, renames)
+		}
+	}
+}
+
+// renameSecurity 安全要求以方案名为键, 不通过 $ref 引用, 需单独改写
+func renameSecurity(v interface{}, renames map[string]string) {
+	list, _ := v.([]interface{})
+	for _, item := range list {
+		req, _ := item.(map[string]interface{})
+		for old, name := range renames {
+			if scopes, ok := req[old]; ok {
+				delete(req, old)
+				req[name] = scopes
+			}
+		}
+	}
+}
+
+// basePath Swagger 2.0 的 basePath 或 OpenAPI 3 第一个服务地址中的路径
+func basePath(doc map[string]interface{}, key string) string {
+	path := str(doc["basePath"])
+	if key == "openapi" {
+		servers, _ := doc["servers"].([]interface{})
+		if len(servers) > 0 {
+			server, _ := servers[0].(map[string]interface{})
+			if u, err := url.Parse(str(server["url"])); err == nil {
+				path = u.Path
+			}
+		}
+	}
+	return strings.TrimSuffix(path, "/")
+}
+
+func setIn(m map[string]interface{}, keys []string, v interface{}) {
+	for _, k := range keys[:len(keys)-1] {
+		next, ok := m[k].(map[string]interface{})
+		if !ok {
+			next = map[string]interface{}{}
+			m[k] = next
+		}
+		m = next
+	}
+	m[keys[len(keys)-1]] = v
+}
+
+func sortedPaths(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func str(v interface{}) string {
+	s, _ := v.(string)
+	return s
+}
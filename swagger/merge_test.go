@@ -0,0 +1,120 @@
+package swagger
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// doc 由 JSON 构造文档
+func doc(t *testing.T, s string) interface{} {
+	var v map[string]interface{}
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestMerge(t *testing.T) {
+	order := doc(t, `{
+		"swagger": "2.0",
+		"info": {"title": "order", "version": "1.0"},
+		"basePath": "/api/",
+		"security": [{"token": []}],
+		"securityDefinitions": {"token": {"type": "apiKey", "name": "X-Token", "in": "header"}},
+		"tags": [{"name": "订单"}],
+		"paths": {"/orders": {"get": {"operationId": "list", "responses": {"200": {"schema": {"$ref": "#/definitions/Item"}}}}}},
+		"definitions": {"Item": {"type": "object", "properties": {"id": {"type": "integer"}}}, "Error": {"type": "string"}}
+	}`)
+	pay := doc(t, `{
+		"swagger": "2.0",
+		"info": {"title": "pay", "version": "2.0"},
+		"securityDefinitions": {"token": {"type": "apiKey", "name": "Authorization", "in": "header"}},
+		"tags": [{"name": "订单"}, {"name": "支付"}],
+		"paths": {"/pay": {"post": {"operationId": "list", "security": [{"token": []}], "responses": {"200": {"schema": {"$ref": "#/definitions/Item"}}}}}},
+		"definitions": {"Item": {"type": "object", "properties": {"no": {"type": "string"}}}, "Error": {"type": "string"}}
+	}`)
+
+	got, err := Merge([]Service{{Name: "order", Doc: order}, {Name: "pay", Doc: pay}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := doc(t, `{
+		"swagger": "2.0",
+		"info": {"title": "order, pay", "description": "- order: order 1.0\n- pay: pay 2.0\n\n`+mergedNote+`", "version": "merged"},
+		"tags": [{"name": "订单"}, {"name": "支付"}],
+		"securityDefinitions": {
+			"token": {"type": "apiKey", "name": "X-Token", "in": "header"},
+			"pay.token": {"type": "apiKey", "name": "Authorization", "in": "header"}
+		},
+		"paths": {
+			"/order/api/orders": {"get": {"operationId": "list", "security": [{"token": []}], "responses": {"200": {"schema": {"$ref": "#/definitions/Item"}}}}},
+			"/pay/pay": {"post": {"operationId": "pay.list", "security": [{"pay.token": []}], "responses": {"200": {"schema": {"$ref": "#/definitions/pay.Item"}}}}}
+		},
+		"definitions": {
+			"Item": {"type": "object", "properties": {"id": {"type": "integer"}}},
+			"pay.Item": {"type": "object", "properties": {"no": {"type": "string"}}},
+			"Error": {"type": "string"}
+		}
+	}`)
+	if !reflect.DeepEqual(got, want) {
+		a, _ := json.MarshalIndent(got, "", "  ")
+		t.Errorf("got %s", a)
+	}
+	// 合并时改写的是副本
+	if _, ok := pay.(map[string]interface{})["definitions"].(map[string]interface{})["pay.Item"]; ok {
+		t.Error("source document should not be modified")
+	}
+}
+
+func TestMergeOpenapiServerPath(t *testing.T) {
+	user := doc(t, `{
+		"openapi": "3.1.0",
+		"servers": [{"url": "https://user.example.com/v1/"}],
+		"paths": {"/users": {"get": {}}}
+	}`)
+	got, err := Merge([]Service{{Name: "user", Doc: user}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	paths := got["paths"].(map[string]interface{})
+	if _, ok := paths["/user/v1/users"]; !ok || len(paths) != 1 {
+		t.Errorf("paths = %v, want /user/v1/users", paths)
+	}
+	if _, ok := got["servers"]; ok {
+		t.Error("merged document should not have servers")
+	}
+}
+
+func TestMergeError(t *testing.T) {
+	tests := []struct {
+		name     string
+		services []Service
+		err      string
+	}{
+		{
+			name: "no service",
+			err:  "no service to merge",
+		},
+		{
+			name:     "unknown document",
+			services: []Service{{Name: "a", Doc: doc(t, `{"info": {}}`)}},
+			err:      "service a: not a swagger or openapi document",
+		},
+		{
+			name: "mixed versions",
+			services: []Service{
+				{Name: "a", Doc: doc(t, `{"swagger": "2.0"}`)},
+				{Name: "b", Doc: doc(t, `{"openapi": "3.1.0"}`)},
+			},
+			err: "service b: cannot merge swagger 2.0 and openapi 3 documents",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Merge(tt.services); err == nil || err.Error() != tt.err {
+				t.Errorf("err = %v, want %s", err, tt.err)
+			}
+		})
+	}
+}
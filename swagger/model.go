@@ -0,0 +1,156 @@
+package swagger
+
+import (
+	"go/token"
+)
+
+// Spec 与版本无关的接口文档模型, Swagger 2.0 与 OpenAPI 3.1 文档均由其生成
+type Spec struct {
+	Info     Info
+	Host     string
+	BasePath string
+	Schemes  []string
+	// Servers OpenAPI 3 的服务地址, 为空时由 Schemes, Host, BasePath 推导
+	Servers []Server
+	Tags    []Tags
+	Paths   Paths
+	// Schemas 可被引用的结构定义, 名称 => 结构
+	Schemas map[string]*Schema
+	// SecuritySchemes 安全方案, 名称 => 方案
+	SecuritySchemes map[string]*SecurityScheme
+	// MiddlewareSecurity 中间件名 => 安全方案名
+	MiddlewareSecurity map[string]string
+	// Patterns 自定义校验规则 => 正则, 初始为内置的 Patterns, 配置文件中的规则只作用于本文档
+	Patterns map[string]string
+	// defs 结构体全限定名 => Schemas 中的名称
+	defs map[string]string
+	// operations operationId => 接口, 用于检查重复
+	operations map[string]Api
+}
+
+func NewSpec() *Spec {
+	s := &Spec{
+		Paths:              make(Paths),
+		Schemas:            make(map[string]*Schema),
+		SecuritySchemes:    make(map[string]*SecurityScheme),
+		MiddlewareSecurity: make(map[string]string),
+		Patterns:           make(map[string]string, len(Patterns)),
+		defs:               make(map[string]string),
+		operations:         make(map[string]Api),
+	}
+	for name, pattern := range Patterns {
+		s.Patterns[name] = pattern
+	}
+	return s
+}
+
+type Info struct {
+	Title          string   `json:"title" yaml:"title"`
+	Description    string   `json:"description,omitempty" yaml:"description,omitempty"`
+	Version        string   `json:"version" yaml:"version"`
+	TermsOfService string   `json:"termsOfService,omitempty" yaml:"termsOfService,omitempty"`
+	Contact        *Contact `json:"contact,omitempty" yaml:"contact,omitempty"`
+	License        *License `json:"license,omitempty" yaml:"license,omitempty"`
+}
+
+type Contact struct {
+	Name  string `json:"name,omitempty" yaml:"name,omitempty"`
+	Url   string `json:"url,omitempty" yaml:"url,omitempty"`
+	Email string `json:"email,omitempty" yaml:"email,omitempty"`
+}
+
+type License struct {
+	Name string `json:"name" yaml:"name"`
+	Url  string `json:"url,omitempty" yaml:"url,omitempty"`
+}
+
+type Server struct {
+	Url         string `json:"url" yaml:"url"`
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+}
+
+type Tags struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+type Path string
+type Method string
+
+type Paths map[Path]map[Method]Api
+
+// Api 一个接口
+type Api struct {
+	// Pos 路由注解的位置
+	Pos         token.Position
+	Path        string
+	Method      string
+	Tags        []string
+	Summary     string
+	Description string
+	OperationId string
+	// Parameters path, query, header 参数
+	Parameters []Parameter
+	// Body 请求体, 没有时为 nil
+	Body      *Body
+	Responses []Response
+	// Security 满足其中一项即可, 为空时不需要认证
+	Security []SecurityRequirement
+}
+
+type Parameter struct {
+	Name        string
+	In          string
+	Description string
+	Required    bool
+	Schema      *Schema
+}
+
+// Body 请求体, 其 Schema 为对象时每个属性对应一个字段
+type Body struct {
+	// ContentType 如 application/json, application/x-www-form-urlencoded
+	ContentType string
+	Description string
+	Required    bool
+	Schema      *Schema
+}
+
+type Response struct {
+	Code        int
+	Description string
+	Schema      *Schema
+}
+
+// Schema 字段或参数的结构, 由各版本的生成器转换为对应的 JSON Schema
+type Schema struct {
+	// Ref 引用 Spec.Schemas 中的定义名称
+	Ref         string
+	Type        string
+	Format      string
+	Description string
+	Enum        []interface{}
+	// Items 数组的元素
+	Items *Schema
+	// Properties 对象的属性, Required 为必填的属性名
+	Properties map[string]*Schema
+	Required   []string
+	// AdditionalProperties map 的值
+	AdditionalProperties *Schema
+	// Nullable 可以为 null, 如指针类型的字段
+	Nullable bool
+	// OneOf 取值为其中之一, 如接口类型的字段
+	OneOf []*Schema
+	// Minimum, Maximum 数值的取值范围, Exclusive 为 true 时不含边界
+	Minimum          *float64
+	Maximum          *float64
+	ExclusiveMinimum bool
+	ExclusiveMaximum bool
+	// MinLength, MaxLength 字符串的长度范围
+	MinLength *int
+	MaxLength *int
+	// MinItems, MaxItems 数组的元素个数范围
+	MinItems *int
+	MaxItems *int
+	// Pattern 字符串需匹配的正则
+	Pattern string
+}
@@ -1,71 +1,207 @@
 package swagger
 
-import "github.com/daodao97/egin/lib"
+import (
+	"net/url"
+	"sort"
+	"strconv"
 
-type Api struct {
-	Path        string      `json:"-"`
-	Method      string      `json:"-" default:"GET"`
-	Tags        []string    `json:"tags" default:"[]"`
-	Summary     string      `json:"summary"`
-	Description string      `json:"description"`
-	OperationId string      `json:"operationId"`
-	Produces    []string    `json:"produces" default:"[]"`
-	Parameters  []Parameter `json:"parameters" default:"[]"`
-	Response    []Response  `json:"response"`
+	"github.com/pkg/errors"
+)
+
+const (
+	// Version2 Swagger 2.0
+	Version2 = "2.0"
+	// Version31 OpenAPI 3.1
+	Version31 = "3.1"
+)
+
+// Document 生成指定版本的文档
+func (s *Spec) Document(version string) (interface{}, error) {
+	switch version {
+	case Version2:
+		return s.Swagger(), nil
+	case Version31:
+		return s.OpenAPI(), nil
+	}
+	return nil, errors.Errorf("unsupported spec version %q, want %s or %s", version, Version2, Version31)
+}
+
+type Swagger struct {
+	Swagger     string                                  `json:"swagger"`
+	Info        Info                                    `json:"info"`
+	Host        string                                  `json:"host,omitempty"`
+	BasePath    string                                  `json:"basePath,omitempty"`
+	Tags        []Tags                                  `json:"tags"`
+	Schemes     []string                                `json:"schemes"`
+	Paths       map[string]map[string]*SwaggerOperation `json:"paths"`
+	Definitions map[string]*SchemaObject                `json:"definitions,omitempty"`
+
+	SecurityDefinitions map[string]*SwaggerSecurityScheme `json:"securityDefinitions,omitempty"`
 }
 
-type Parameter struct {
-	Name        string `json:"name"`
-	In          string `json:"in"`
-	Description string `json:"description"`
-	Required    bool   `json:"required"`
-	Type        string `json:"type"`
+type SwaggerOperation struct {
+	Tags        []string                   `json:"tags"`
+	Summary     string                     `json:"summary"`
+	Description string                     `json:"description"`
+	OperationId string                     `json:"operationId,omitempty"`
+	Consumes    []string                   `json:"consumes,omitempty"`
+	Parameters  []SwaggerParameter         `json:"parameters"`
+	Responses   map[string]SwaggerResponse `json:"responses"`
+	Security    []SecurityRequirement      `json:"security,omitempty"`
 }
 
-type Response struct {
-	Code        int
-	Description string
-	Schema      Schema
+// SwaggerParameter body 参数使用 Schema, 其他参数的类型直接写在参数上
+type SwaggerParameter struct {
+	Name        string        `json:"name"`
+	In          string        `json:"in"`
+	Description string        `json:"description"`
+	Required    bool          `json:"required"`
+	Schema      *SchemaObject `json:"schema,omitempty"`
+	Type        string        `json:"type,omitempty"`
+	Format      string        `json:"format,omitempty"`
+	Enum        []interface{} `json:"enum,omitempty"`
+	Items       *SchemaObject `json:"items,omitempty"`
+	// CollectionFormat 数组参数的格式, gin 按重复的键绑定数组, 即 multi
+	CollectionFormat string `json:"collectionFormat,omitempty"`
+	// 以下为参数的校验约束, 含义与 SchemaObject 相同
+	Minimum          *float64    `json:"minimum,omitempty"`
+	Maximum          *float64    `json:"maximum,omitempty"`
+	ExclusiveMinimum interface{} `json:"exclusiveMinimum,omitempty"`
+	ExclusiveMaximum interface{} `json:"exclusiveMaximum,omitempty"`
+	MinLength        *int        `json:"minLength,omitempty"`
+	MaxLength        *int        `json:"maxLength,omitempty"`
+	MinItems         *int        `json:"minItems,omitempty"`
+	MaxItems         *int        `json:"maxItems,omitempty"`
+	Pattern          string      `json:"pattern,omitempty"`
 }
 
-type Schema struct {
-	Ref string `json:"$ref"`
+type SwaggerResponse struct {
+	Description string        `json:"description"`
+	Schema      *SchemaObject `json:"schema,omitempty"`
 }
 
-type Path string
-type Method string
+var swaggerSchema = schemaEncoder{refPrefix: "#/definitions/"}
 
-type Paths map[Path]map[Method]Api
+// Swagger 生成 Swagger 2.0 文档
+func (s *Spec) Swagger() *Swagger {
+	doc := &Swagger{
+		Swagger:  Version2,
+		Info:     s.Info,
+		Host:     s.Host,
+		BasePath: s.BasePath,
+		Tags:     s.Tags,
+		Schemes:  s.Schemes,
+		Paths:    make(map[string]map[string]*SwaggerOperation),
+	}
+	if len(s.Servers) > 0 {
+		// 与 OpenAPI 3 相同, 有服务地址时以其为准. Swagger 2.0 只能描述一个服务地址, 取第一个
+		if u, err := url.Parse(s.Servers[0].Url); err == nil {
+			doc.Host, doc.BasePath, doc.Schemes = u.Host, u.Path, nil
+			if u.Scheme != "" {
+				doc.Schemes = []string{u.Scheme}
+			}
+		}
+	}
+	if doc.Tags == nil {
+		doc.Tags = []Tags{}
+	}
+	if doc.Schemes == nil {
+		doc.Schemes = []string{}
+	}
+	if len(s.Schemas) > 0 {
+		doc.Definitions = make(map[string]*SchemaObject, len(s.Schemas))
+		for name, schema := range s.Schemas {
+			doc.Definitions[name] = swaggerSchema.encode(schema)
+		}
+	}
+	if len(s.SecuritySchemes) > 0 {
+		doc.SecurityDefinitions = make(map[string]*SwaggerSecurityScheme, len(s.SecuritySchemes))
+		for name, scheme := range s.SecuritySchemes {
+			doc.SecurityDefinitions[name] = swaggerSecurityScheme(scheme)
+		}
+	}
+	for path, methods := range s.Paths {
+		doc.Paths[string(path)] = make(map[string]*SwaggerOperation, len(methods))
+		for method, api := range methods {
+			doc.Paths[string(path)][string(method)] = swaggerOperation(api)
+		}
+	}
+	return doc
+}
 
-type Tags struct {
-	Name        string `json:"name"`
-	Description string `json:"description"`
+func swaggerOperation(api Api) *SwaggerOperation {
+	op := &SwaggerOperation{
+		Tags:        api.Tags,
+		Summary:     api.Summary,
+		Description: api.Description,
+		OperationId: api.OperationId,
+		Parameters:  []SwaggerParameter{},
+		Responses:   make(map[string]SwaggerResponse),
+		Security:    api.Security,
+	}
+	for _, p := range api.Parameters {
+		op.Parameters = append(op.Parameters, swaggerParameter(p.Name, p.In, p.Description, p.Required, p.Schema))
+	}
+	if b := api.Body; b != nil {
+		op.Consumes = []string{b.ContentType}
+		if b.ContentType == "application/json" {
+			op.Parameters = append(op.Parameters, SwaggerParameter{
+				Name:        "body",
+				In:          "body",
+				Description: b.Description,
+				Required:    b.Required,
+				Schema:      swaggerSchema.encode(b.Schema),
+			})
+		} else {
+			// 表单请求体的每个属性作为一个 formData 参数
+			for _, name := range sortedKeys(b.Schema.Properties) {
+				prop := b.Schema.Properties[name]
+				op.Parameters = append(op.Parameters, swaggerParameter(name, "formData", prop.Description, contains(b.Schema.Required, name), prop))
+			}
+		}
+	}
+	for _, r := range api.Responses {
+		op.Responses[strconv.Itoa(r.Code)] = SwaggerResponse{Description: r.Description, Schema: swaggerSchema.encode(r.Schema)}
+	}
+	if len(op.Responses) == 0 {
+		op.Responses["200"] = SwaggerResponse{Description: "OK"}
+	}
+	return op
 }
 
-type Swagger struct {
-	Swagger string `default:"2.0" json:"swagger"`
-	Info    struct {
-		Description    string `json:"description" default:"egin swagger"`
-		Version        string `json:"version"`
-		Title          string `json:"title"`
-		TermsOfService string `json:"termsOfService"`
-		Contact        struct {
-			Email string `json:"email"`
-		} `json:"contact"`
-		License struct {
-			Name string `json:"name"`
-			Url  string `json:"url"`
-		} `json:"license"`
-	} `json:"info"`
-	Host     string   `json:"host,omitempty"`
-	BasePath string   `json:"basePath,omitempty"`
-	Tags     []Tags   `json:"tags" default:"[]"`
-	Schemes  []string `json:"schemes" default:"[]"`
-	Paths    Paths    `json:"paths"`
+// swaggerParameter 非 body 参数不能使用 schema, 类型信息直接写在参数上
+func swaggerParameter(name string, in string, desc string, required bool, schema *Schema) SwaggerParameter {
+	p := SwaggerParameter{Name: name, In: in, Description: desc, Required: required}
+	if o := swaggerSchema.encode(schema); o != nil {
+		p.Type, _ = o.Type.(string)
+		p.Format, p.Enum, p.Items = o.Format, o.Enum, o.Items
+		p.Minimum, p.Maximum, p.ExclusiveMinimum, p.ExclusiveMaximum = o.Minimum, o.Maximum, o.ExclusiveMinimum, o.ExclusiveMaximum
+		p.MinLength, p.MaxLength, p.MinItems, p.MaxItems, p.Pattern = o.MinLength, o.MaxLength, o.MinItems, o.MaxItems, o.Pattern
+		if o.Ref != "" {
+			p.Type = "object"
+		}
+	}
+	// 默认的 csv 会发送 ids=1,2, gin 的表单绑定需要 ids=1&ids=2
+	if p.Type == "array" && (in == "query" || in == "formData") {
+		p.CollectionFormat = "multi"
+	}
+	return p
+}
+
+func sortedKeys(m map[string]*Schema) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
 }
 
-func NewSwagger() *Swagger {
-	s := &Swagger{}
-	lib.MustSet(s)
-	return s
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
 }
@@ -0,0 +1,148 @@
+package swagger
+
+import (
+	"strconv"
+)
+
+type OpenAPI struct {
+	OpenAPI    string                                  `json:"openapi"`
+	Info       Info                                    `json:"info"`
+	Servers    []Server                                `json:"servers,omitempty"`
+	Tags       []Tags                                  `json:"tags,omitempty"`
+	Paths      map[string]map[string]*OpenAPIOperation `json:"paths"`
+	Components *Components                             `json:"components,omitempty"`
+}
+
+type Components struct {
+	Schemas         map[string]*SchemaObject          `json:"schemas,omitempty"`
+	SecuritySchemes map[string]*OpenAPISecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+type OpenAPIOperation struct {
+	Tags        []string                   `json:"tags,omitempty"`
+	Summary     string                     `json:"summary,omitempty"`
+	Description string                     `json:"description,omitempty"`
+	OperationId string                     `json:"operationId,omitempty"`
+	Parameters  []OpenAPIParameter         `json:"parameters,omitempty"`
+	RequestBody *RequestBody               `json:"requestBody,omitempty"`
+	Responses   map[string]OpenAPIResponse `json:"responses"`
+	Security    []SecurityRequirement      `json:"security,omitempty"`
+}
+
+type OpenAPIParameter struct {
+	Name        string        `json:"name"`
+	In          string        `json:"in"`
+	Description string        `json:"description,omitempty"`
+	Required    bool          `json:"required,omitempty"`
+	Schema      *SchemaObject `json:"schema,omitempty"`
+}
+
+type RequestBody struct {
+	Description string               `json:"description,omitempty"`
+	Required    bool                 `json:"required,omitempty"`
+	Content     map[string]MediaType `json:"content"`
+}
+
+type MediaType struct {
+	Schema *SchemaObject `json:"schema,omitempty"`
+}
+
+type OpenAPIResponse struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+var openAPISchema = schemaEncoder{refPrefix: "#/components/schemas/", v3: true}
+
+// OpenAPI 生成 OpenAPI 3.1 文档
+func (s *Spec) OpenAPI() *OpenAPI {
+	doc := &OpenAPI{
+		OpenAPI: Version31 + ".0",
+		Info:    s.Info,
+		Servers: s.servers(),
+		Tags:    s.Tags,
+		Paths:   make(map[string]map[string]*OpenAPIOperation),
+	}
+	if len(s.Schemas) > 0 || len(s.SecuritySchemes) > 0 {
+		doc.Components = &Components{}
+	}
+	if len(s.Schemas) > 0 {
+		doc.Components.Schemas = make(map[string]*SchemaObject, len(s.Schemas))
+		for name, schema := range s.Schemas {
+			doc.Components.Schemas[name] = openAPISchema.encode(schema)
+		}
+	}
+	if len(s.SecuritySchemes) > 0 {
+		doc.Components.SecuritySchemes = make(map[string]*OpenAPISecurityScheme, len(s.SecuritySchemes))
+		for name, scheme := range s.SecuritySchemes {
+			doc.Components.SecuritySchemes[name] = openAPISecurityScheme(scheme)
+		}
+	}
+	for path, methods := range s.Paths {
+		doc.Paths[string(path)] = make(map[string]*OpenAPIOperation, len(methods))
+		for method, api := range methods {
+			doc.Paths[string(path)][string(method)] = openAPIOperation(api)
+		}
+	}
+	return doc
+}
+
+// servers 未配置服务地址时由 Schemes, Host, BasePath 推导
+func (s *Spec) servers() []Server {
+	if len(s.Servers) > 0 {
+		return s.Servers
+	}
+	if s.Host == "" {
+		if s.BasePath != "" {
+			return []Server{{Url: s.BasePath}}
+		}
+		return nil
+	}
+	schemes := s.Schemes
+	if len(schemes) == 0 {
+		schemes = []string{"http"}
+	}
+	var result []Server
+	for _, scheme := range schemes {
+		result = append(result, Server{Url: scheme + "://" + s.Host + s.BasePath})
+	}
+	return result
+}
+
+func openAPIOperation(api Api) *OpenAPIOperation {
+	op := &OpenAPIOperation{
+		Tags:        api.Tags,
+		Summary:     api.Summary,
+		Description: api.Description,
+		OperationId: api.OperationId,
+		Responses:   make(map[string]OpenAPIResponse),
+		Security:    api.Security,
+	}
+	for _, p := range api.Parameters {
+		op.Parameters = append(op.Parameters, OpenAPIParameter{
+			Name:        p.Name,
+			In:          p.In,
+			Description: p.Description,
+			Required:    p.Required,
+			Schema:      openAPISchema.encode(p.Schema),
+		})
+	}
+	if b := api.Body; b != nil {
+		op.RequestBody = &RequestBody{
+			Description: b.Description,
+			Required:    b.Required,
+			Content:     map[string]MediaType{b.ContentType: {Schema: openAPISchema.encode(b.Schema)}},
+		}
+	}
+	for _, r := range api.Responses {
+		resp := OpenAPIResponse{Description: r.Description}
+		if r.Schema != nil {
+			resp.Content = map[string]MediaType{"application/json": {Schema: openAPISchema.encode(r.Schema)}}
+		}
+		op.Responses[strconv.Itoa(r.Code)] = resp
+	}
+	if len(op.Responses) == 0 {
+		op.Responses["200"] = OpenAPIResponse{Description: "OK"}
+	}
+	return op
+}
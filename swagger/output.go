@@ -0,0 +1,104 @@
+package swagger
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	FormatJSON = "json"
+	FormatYAML = "yaml"
+)
+
+// Marshal 按格式序列化文档. 对象的键按结构体字段顺序输出, map 的键按字典序输出,
+// 相同的代码每次生成的内容一致, 便于提交后对比差异
+func Marshal(doc interface{}, format string) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	// pattern 等字段中的 < > & 不转义, 保持可读
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return nil, errors.Wrap(err, "marshal json")
+	}
+	switch format {
+	case FormatJSON:
+		return buf.Bytes(), nil
+	case FormatYAML:
+		// 经由 JSON 转换, 字段名与 JSON 一致, MapSlice 保留键的顺序
+		var ms yaml.MapSlice
+		if err := yaml.Unmarshal(buf.Bytes(), &ms); err != nil {
+			return nil, errors.Wrap(err, "convert json to yaml")
+		}
+		out, err := yaml.Marshal(ms)
+		return out, errors.Wrap(err, "marshal yaml")
+	}
+	return nil, errors.Errorf("unsupported format %q, want %s or %s", format, FormatJSON, FormatYAML)
+}
+
+// Write 将文档写入 dir/swagger.json, dir/swagger.yaml, 返回写入的文件
+func Write(doc interface{}, dir string, formats []string) (files []string, err error) {
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, errors.Wrapf(err, "create %s", dir)
+	}
+	for _, format := range formats {
+		content, err := Marshal(doc, format)
+		if err != nil {
+			return files, err
+		}
+		name := filepath.Join(dir, "swagger."+format)
+		if err := ioutil.WriteFile(name, content, os.FileMode(0644)); err != nil {
+			return files, errors.Wrapf(err, "write %s", name)
+		}
+		files = append(files, name)
+	}
+	return files, nil
+}
+
+// Read 读取已生成的文档文件, 扩展名为 .yaml 或 .yml 时按 yaml 解析, 否则按 json 解析
+func Read(file string) (map[string]interface{}, error) {
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, errors.Wrapf(err, "read %s", file)
+	}
+	var doc interface{}
+	switch filepath.Ext(file) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(content, &doc)
+		doc = jsonValue(doc)
+	default:
+		err = json.Unmarshal(content, &doc)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "parse %s", file)
+	}
+	m, ok := doc.(map[string]interface{})
+	if !ok || versionKey(m) == "" {
+		return nil, errors.Errorf("%s: not a swagger or openapi document", file)
+	}
+	return m, nil
+}
+
+// jsonValue yaml 解析出的对象键为 interface{}, 转为与 json 一致的 map[string]interface{}
+func jsonValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			m[fmt.Sprint(k)] = jsonValue(item)
+		}
+		return m
+	case []interface{}:
+		for i, item := range v {
+			v[i] = jsonValue(item)
+		}
+	}
+	return v
+}
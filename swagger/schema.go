@@ -0,0 +1,119 @@
+package swagger
+
+// SchemaObject 输出到文档中的 JSON Schema
+type SchemaObject struct {
+	Ref string `json:"$ref,omitempty"`
+	// Type OpenAPI 3.1 中可为 []string, 如 ["string", "null"]
+	Type                 interface{}              `json:"type,omitempty"`
+	Format               string                   `json:"format,omitempty"`
+	Description          string                   `json:"description,omitempty"`
+	Enum                 []interface{}            `json:"enum,omitempty"`
+	Items                *SchemaObject            `json:"items,omitempty"`
+	Properties           map[string]*SchemaObject `json:"properties,omitempty"`
+	Required             []string                 `json:"required,omitempty"`
+	AdditionalProperties *SchemaObject            `json:"additionalProperties,omitempty"`
+	OneOf                []*SchemaObject          `json:"oneOf,omitempty"`
+	Minimum              *float64                 `json:"minimum,omitempty"`
+	Maximum              *float64                 `json:"maximum,omitempty"`
+	// ExclusiveMinimum Swagger 2.0 中为 bool, OpenAPI 3.1 中为边界值
+	ExclusiveMinimum interface{} `json:"exclusiveMinimum,omitempty"`
+	ExclusiveMaximum interface{} `json:"exclusiveMaximum,omitempty"`
+	MinLength        *int        `json:"minLength,omitempty"`
+	MaxLength        *int        `json:"maxLength,omitempty"`
+	MinItems         *int        `json:"minItems,omitempty"`
+	MaxItems         *int        `json:"maxItems,omitempty"`
+	Pattern          string      `json:"pattern,omitempty"`
+	// XNullable Swagger 2.0 没有 null 类型, 以扩展字段表示
+	XNullable bool `json:"x-nullable,omitempty"`
+}
+
+// schemaEncoder 将 Schema 转为指定版本的 JSON Schema
+type schemaEncoder struct {
+	// refPrefix 如 #/definitions/, #/components/schemas/
+	refPrefix string
+	// v3 OpenAPI 3.1, 支持 oneOf 与 null 类型
+	v3 bool
+}
+
+func (e schemaEncoder) encode(s *Schema) *SchemaObject {
+	if s == nil {
+		return nil
+	}
+	o := &SchemaObject{
+		Format:               s.Format,
+		Description:          s.Description,
+		Enum:                 s.Enum,
+		Items:                e.encode(s.Items),
+		Required:             s.Required,
+		AdditionalProperties: e.encode(s.AdditionalProperties),
+		Minimum:              s.Minimum,
+		Maximum:              s.Maximum,
+		MinLength:            s.MinLength,
+		MaxLength:            s.MaxLength,
+		MinItems:             s.MinItems,
+		MaxItems:             s.MaxItems,
+		Pattern:              s.Pattern,
+	}
+	e.exclusive(o, s)
+	if s.Type != "" {
+		o.Type = s.Type
+	}
+	if s.Ref != "" {
+		o.Ref = e.refPrefix + s.Ref
+	}
+	if len(s.Properties) > 0 {
+		o.Properties = make(map[string]*SchemaObject, len(s.Properties))
+		for k, v := range s.Properties {
+			o.Properties[k] = e.encode(v)
+		}
+	}
+	if e.v3 {
+		for _, v := range s.OneOf {
+			o.OneOf = append(o.OneOf, e.encode(v))
+		}
+	} else if len(s.OneOf) > 0 && s.Type == "" {
+		// Swagger 2.0 不支持 oneOf, 退化为 object
+		o.Type = "object"
+	}
+	if s.Nullable {
+		e.nullable(o)
+	}
+	return o
+}
+
+// exclusive 2.0 中 exclusiveMinimum 为修饰 minimum 的 bool, 3.1 中直接为边界值
+func (e schemaEncoder) exclusive(o *SchemaObject, s *Schema) {
+	if s.ExclusiveMinimum && s.Minimum != nil {
+		o.ExclusiveMinimum = true
+		if e.v3 {
+			o.Minimum, o.ExclusiveMinimum = nil, *s.Minimum
+		}
+	}
+	if s.ExclusiveMaximum && s.Maximum != nil {
+		o.ExclusiveMaximum = true
+		if e.v3 {
+			o.Maximum, o.ExclusiveMaximum = nil, *s.Maximum
+		}
+	}
+}
+
+// nullable 3.1 中以 null 类型表示可空, 引用与 oneOf 需包装为 oneOf 才能追加 null
+func (e schemaEncoder) nullable(o *SchemaObject) {
+	if !e.v3 {
+		o.XNullable = true
+		return
+	}
+	switch {
+	case o.Ref != "":
+		ref := &SchemaObject{Ref: o.Ref}
+		o.Ref = ""
+		o.OneOf = []*SchemaObject{ref, {Type: "null"}}
+	case len(o.OneOf) > 0:
+		o.OneOf = append(o.OneOf, &SchemaObject{Type: "null"})
+	case o.Type != nil:
+		o.Type = []string{o.Type.(string), "null"}
+		if len(o.Enum) > 0 {
+			o.Enum = append(append([]interface{}{}, o.Enum...), nil)
+		}
+	}
+}
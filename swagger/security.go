@@ -0,0 +1,213 @@
+package swagger
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/daodao97/egin-tools/annotation"
+	"github.com/daodao97/egin-tools/diag"
+)
+
+// 安全方案的类型
+const (
+	SecurityApiKey = "apiKey"
+	SecurityBasic  = "basic"
+	SecurityBearer = "bearer"
+	SecurityOAuth2 = "oauth2"
+)
+
+// SecurityScheme 安全方案, 在配置文件的 securitySchemes 中声明, 如
+//
+//	securitySchemes:
+//	  jwt:
+//	    type: bearer
+//	    bearerFormat: JWT
+//	  token:
+//	    type: apiKey
+//	    in: header
+//	    name: X-Token
+type SecurityScheme struct {
+	Type        string `yaml:"type"`
+	Description string `yaml:"description"`
+	// Name, In apiKey 的参数名与位置, 位置为 header, query 或 cookie
+	Name string `yaml:"name"`
+	In   string `yaml:"in"`
+	// BearerFormat bearer 令牌的格式, 如 JWT
+	BearerFormat string `yaml:"bearerFormat"`
+	// Flow oauth2 的授权方式, 为 implicit, password, clientCredentials 或 authorizationCode
+	Flow             string            `yaml:"flow"`
+	AuthorizationUrl string            `yaml:"authorizationUrl"`
+	TokenUrl         string            `yaml:"tokenUrl"`
+	Scopes           map[string]string `yaml:"scopes"`
+}
+
+// SecurityRequirement 安全方案名 => 需要的 scope, 一个要求中的所有方案需同时满足
+type SecurityRequirement map[string][]string
+
+// KnownMiddleware egin 中间件 => 安全方案的类型. 配置中没有指定中间件对应的方案时,
+// 若只声明了一个该类型的方案, 使用该中间件的接口自动要求该方案
+var KnownMiddleware = map[string]string{
+	"Auth":      SecurityApiKey,
+	"JwtAuth":   SecurityBearer,
+	"BasicAuth": SecurityBasic,
+}
+
+// oauthFlows 授权方式在 OpenAPI 3 与 Swagger 2.0 中的名称
+var oauthFlows = map[string]string{
+	"implicit":          "implicit",
+	"password":          "password",
+	"clientCredentials": "application",
+	"authorizationCode": "accessCode",
+}
+
+func (s *SecurityScheme) validate() error {
+	switch s.Type {
+	case SecurityApiKey:
+		if s.Name == "" {
+			return errors.New("apiKey requires name")
+		}
+		if s.In != "header" && s.In != "query" && s.In != "cookie" {
+			return errors.Errorf("apiKey in must be header, query or cookie, got %q", s.In)
+		}
+	case SecurityBasic, SecurityBearer:
+	case SecurityOAuth2:
+		if _, ok := oauthFlows[s.Flow]; !ok {
+			return errors.Errorf("unsupported oauth2 flow %q", s.Flow)
+		}
+		if (s.Flow == "implicit" || s.Flow == "authorizationCode") && s.AuthorizationUrl == "" {
+			return errors.Errorf("oauth2 flow %s requires authorizationUrl", s.Flow)
+		}
+		if s.Flow != "implicit" && s.TokenUrl == "" {
+			return errors.Errorf("oauth2 flow %s requires tokenUrl", s.Flow)
+		}
+	default:
+		return errors.Errorf("unsupported security type %q, want apiKey, basic, bearer or oauth2", s.Type)
+	}
+	return nil
+}
+
+// transSecurity 接口的安全要求, 优先级为处理函数的 @Security > 中间件 > 控制器的 @Security
+func (b *builder) transSecurity(handler *annotation.Handler, c Controller) []SecurityRequirement {
+	if len(handler.Security) > 0 {
+		return b.requirements(handler.Security)
+	}
+	req := SecurityRequirement{}
+	for _, m := range handler.Middleware {
+		if name, ok := b.spec.middlewareScheme(m); ok {
+			req[name] = []string{}
+		}
+	}
+	if len(req) > 0 {
+		return []SecurityRequirement{req}
+	}
+	return b.requirements(c.Security)
+}
+
+func (b *builder) requirements(list []annotation.Security) (result []SecurityRequirement) {
+	for _, v := range list {
+		if _, ok := b.spec.SecuritySchemes[v.Name]; !ok {
+			diag.Errorf(v.Pos, diag.UnknownSecurity, "@Security %s: security scheme not defined", v.Name)
+			continue
+		}
+		scopes := v.Scopes
+		if scopes == nil {
+			scopes = []string{}
+		}
+		result = append(result, SecurityRequirement{v.Name: scopes})
+	}
+	return result
+}
+
+// middlewareScheme 中间件对应的安全方案, 如 @Middleware JwtAuth 或 @Middleware RateLimit(10, 60)
+func (s *Spec) middlewareScheme(middleware string) (string, bool) {
+	if i := strings.Index(middleware, "("); i >= 0 {
+		middleware = middleware[:i]
+	}
+	if name, ok := s.MiddlewareSecurity[middleware]; ok {
+		_, exist := s.SecuritySchemes[name]
+		return name, exist
+	}
+	typ, ok := KnownMiddleware[middleware]
+	if !ok {
+		return "", false
+	}
+	var found []string
+	for name, scheme := range s.SecuritySchemes {
+		if scheme.Type == typ {
+			found = append(found, name)
+		}
+	}
+	if len(found) != 1 {
+		return "", false
+	}
+	return found[0], true
+}
+
+// SwaggerSecurityScheme Swagger 2.0 的 securityDefinitions, 没有 bearer 类型, 以 Authorization 请求头表示
+type SwaggerSecurityScheme struct {
+	Type             string `json:"type"`
+	Description      string `json:"description,omitempty"`
+	Name             string `json:"name,omitempty"`
+	In               string `json:"in,omitempty"`
+	Flow             string `json:"flow,omitempty"`
+	AuthorizationUrl string `json:"authorizationUrl,omitempty"`
+	TokenUrl         string `json:"tokenUrl,omitempty"`
+	// Scopes oauth2 必须有 scopes, 为接口类型时空 map 也会输出
+	Scopes interface{} `json:"scopes,omitempty"`
+}
+
+func swaggerSecurityScheme(s *SecurityScheme) *SwaggerSecurityScheme {
+	o := &SwaggerSecurityScheme{Type: s.Type, Description: s.Description, Name: s.Name, In: s.In}
+	switch s.Type {
+	case SecurityBearer:
+		o.Type, o.Name, o.In = SecurityApiKey, "Authorization", "header"
+		if o.Description == "" {
+			o.Description = strings.TrimSpace("Bearer " + s.BearerFormat + " token, 格式为 Bearer {token}")
+		}
+	case SecurityOAuth2:
+		o.Flow, o.AuthorizationUrl, o.TokenUrl = oauthFlows[s.Flow], s.AuthorizationUrl, s.TokenUrl
+		o.Scopes = scopes(s.Scopes)
+	}
+	return o
+}
+
+// OpenAPISecurityScheme OpenAPI 3 的 securitySchemes
+type OpenAPISecurityScheme struct {
+	Type         string                `json:"type"`
+	Description  string                `json:"description,omitempty"`
+	Name         string                `json:"name,omitempty"`
+	In           string                `json:"in,omitempty"`
+	Scheme       string                `json:"scheme,omitempty"`
+	BearerFormat string                `json:"bearerFormat,omitempty"`
+	Flows        map[string]*OAuthFlow `json:"flows,omitempty"`
+}
+
+type OAuthFlow struct {
+	AuthorizationUrl string            `json:"authorizationUrl,omitempty"`
+	TokenUrl         string            `json:"tokenUrl,omitempty"`
+	Scopes           map[string]string `json:"scopes"`
+}
+
+func openAPISecurityScheme(s *SecurityScheme) *OpenAPISecurityScheme {
+	o := &OpenAPISecurityScheme{Type: s.Type, Description: s.Description}
+	switch s.Type {
+	case SecurityApiKey:
+		o.Name, o.In = s.Name, s.In
+	case SecurityBasic, SecurityBearer:
+		o.Type, o.Scheme, o.BearerFormat = "http", s.Type, s.BearerFormat
+	case SecurityOAuth2:
+		o.Flows = map[string]*OAuthFlow{
+			s.Flow: {AuthorizationUrl: s.AuthorizationUrl, TokenUrl: s.TokenUrl, Scopes: scopes(s.Scopes)},
+		}
+	}
+	return o
+}
+
+// scopes 规范要求 scopes 必须存在, 没有时输出空对象
+func scopes(m map[string]string) map[string]string {
+	if m == nil {
+		return map[string]string{}
+	}
+	return m
+}
@@ -1,168 +1,399 @@
 package swagger
 
 import (
-	"errors"
-	"fmt"
-	"regexp"
+	"go/token"
+	"net/http"
 	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
 
 	"github.com/daodao97/egin/lib"
 
+	"github.com/daodao97/egin-tools/annotation"
+	"github.com/daodao97/egin-tools/diag"
 	"github.com/daodao97/egin-tools/parser"
 )
 
-var (
-	matchController = regexp.MustCompile(`@Controller .*`)
-	matchAnyApi     = regexp.MustCompile(`@AnyApi .*`)
-	matchGetApi     = regexp.MustCompile(`@GetApi .*`)
-	matchPostApi    = regexp.MustCompile(`@PostApi .*`)
-	matchPutApi     = regexp.MustCompile(`@PutApi .*`)
-	matchDeleteApi  = regexp.MustCompile(`@DeleteApi .*`)
-	matchDesc       = regexp.MustCompile(`@Desc .*`)
-	matchTag        = regexp.MustCompile(`@Tag .*`)
-	matchSummary    = regexp.MustCompile(`@Summary .*`)
-	matchParams     = regexp.MustCompile(`@Params .*`)
-)
-
 type Controller struct {
-	Tag  string
-	Desc string
+	Tag      string
+	Desc     string
+	Security []annotation.Security
 }
 
-func transController(info parser.StructInfo) (c Controller) {
-	c.Tag = info.Name
-	for _, v := range info.Doc {
-		if matchController.MatchString(v) {
-			token := explode(v)
-			if token[1] != "" {
-				c.Tag = token[1]
-			}
-			if len(token) > 2 && token[2] != "" {
-				c.Desc = token[2]
-			}
+func transController(info parser.StructInfo) (c Controller, err error) {
+	ctrl, err := annotation.ParseController(info.Name, info.Doc, info.Pos)
+	c.Tag = ctrl.Tag
+	c.Desc = ctrl.Desc
+	c.Security = ctrl.Security
+	return c, err
+}
 
-			continue
-		}
-	}
-	return c
+// builder 将解析结果转换为文档模型, 引用到的结构体注册为 spec.Schemas 中的定义
+type builder struct {
+	spec *Spec
+	prog *parser.Program
 }
 
-func transApi(sf parser.StructFunc, info []parser.StructInfo) (api Api, err error) {
+func (b *builder) transApi(sf parser.StructFunc, owner parser.StructInfo, c Controller) (api Api, err error) {
 	if sf.Doc == nil {
-		return api, errors.New("func doc not found")
+		return api, annotation.ErrNotApi
 	}
-	_ = lib.Set(&api)
-	for _, v := range sf.Doc {
-		if matchAnyApi.MatchString(v) {
-			api.Method = "ANY"
-			api.Path = explode(v)[1]
-			continue
+	handler, err := annotation.ParseHandler(sf.Doc)
+	if err != nil {
+		return api, err
+	}
+	diag.Report(diag.Error, diag.MalformedAnnotation, handler.Errors.Err())
+	api.Method = handler.Method
+	names, path := routeParams(handler.Path)
+	api.Path = path
+	api.Summary = handler.Summary
+	api.Description = handler.Desc
+	api.Tags = handler.Tags
+	api.Pos = handler.Pos
+	api.OperationId = handler.OperationId
+	if api.OperationId == "" {
+		api.OperationId = operationId(owner.Name, sf.Name)
+	}
+	if handler.Params != "" {
+		si, err := b.prog.ResolveStruct(handler.Params, owner.File)
+		if err != nil {
+			diag.Errorf(handler.Find("Params")[0].Pos, diag.UnknownParams, "@Params %s: %s", handler.Params, err)
+		} else {
+			api.Parameters, api.Body = b.transParams(si, api.Method)
 		}
-		if matchGetApi.MatchString(v) {
-			api.Method = "GET"
-			api.Path = explode(v)[1]
-			continue
+	}
+	if len(api.Parameters) == 0 && api.Body == nil {
+		if si, ok := paramsStruct(sf, b.prog); ok {
+			api.Parameters, api.Body = b.transParams(si, api.Method)
 		}
-		if matchPostApi.MatchString(v) {
-			api.Method = "POST"
-			api.Path = explode(v)[1]
-			continue
+	}
+	api.Parameters = append(b.pathParams(sf, names, api.Parameters), api.Parameters...)
+	api.Responses = b.transResponses(handler, sf, owner)
+	api.Security = b.transSecurity(handler, c)
+	return api, nil
+}
+
+// operationId 由控制器与方法名生成, 如 User.Get => userGet
+func operationId(ctrl string, method string) string {
+	if ctrl == "" {
+		return method
+	}
+	r, size := utf8.DecodeRuneInString(ctrl)
+	return string(unicode.ToLower(r)) + ctrl[size:] + method
+}
+
+// routeParams gin 路由中 :name 与 *name 形式的路径参数, 以及改写为 OpenAPI 形式的路径, 如 /user/{id}
+func routeParams(path string) (names []string, result string) {
+	segments := strings.Split(path, "/")
+	for i, v := range segments {
+		if strings.HasPrefix(v, ":") || strings.HasPrefix(v, "*") {
+			names = append(names, v[1:])
+			segments[i] = "{" + v[1:] + "}"
 		}
-		if matchPutApi.MatchString(v) {
-			api.Method = "PUT"
-			api.Path = explode(v)[1]
+	}
+	return names, strings.Join(segments, "/")
+}
+
+// pathParams 路由中的路径参数, 按顺序对应处理函数 ctx 之后的参数, 类型与说明取自处理函数的签名.
+// 参数结构体中已通过 uri 标签声明的路径参数不再重复添加
+func (b *builder) pathParams(sf parser.StructFunc, names []string, declared []Parameter) (result []Parameter) {
+	for i, name := range names {
+		if hasParam(declared, name, "path") {
 			continue
 		}
-		if matchDeleteApi.MatchString(v) {
-			api.Method = "DELETE"
-			api.Path = explode(v)[1]
-			continue
+		param := Parameter{Name: name, In: "path", Required: true}
+		if i+1 < len(sf.Params) {
+			v := sf.Params[i+1]
+			if _, ok := b.prog.Lookup(v.Type); !ok {
+				param.Schema = b.schemaOf(v.Type)
+				param.Description = v.Description()
+			}
 		}
-		if matchDesc.MatchString(v) {
-			api.Description = strings.TrimPrefix(v, "@Desc")
-			continue
+		if param.Schema == nil {
+			param.Schema = &Schema{Type: "string"}
 		}
-		if matchTag.MatchString(v) {
-			api.Tags = explode(v)[1:]
-			continue
+		result = append(result, param)
+	}
+	return result
+}
+
+func hasParam(ps []Parameter, name string, in string) bool {
+	for _, v := range ps {
+		if v.Name == name && v.In == in {
+			return true
 		}
-		if matchSummary.MatchString(v) {
-			api.Summary = strings.TrimPrefix(v, "@Summary")
-			continue
+	}
+	return false
+}
+
+// transResponses @Response 声明的响应, 未声明 200 或 200 未写类型时由处理函数的返回值推导.
+// 处理函数有返回值时由 egin.Response 输出, 数据包装在 {code, message, data} 中
+func (b *builder) transResponses(handler *annotation.Handler, sf parser.StructFunc, owner parser.StructInfo) (result []Response) {
+	wrap := sf.ResultCount > 0
+	declared := false
+	for _, r := range handler.Responses {
+		resp := Response{Code: r.Code, Description: r.Desc}
+		if resp.Description == "" {
+			resp.Description = http.StatusText(r.Code)
 		}
-		if matchParams.MatchString(v) {
-			p := explode(v)[1]
-			si, err := filterByName(info, p)
+		var data *Schema
+		if r.Type != "" {
+			t, err := b.prog.ResolveType(r.Type, owner.File)
+			if err == nil && !b.known(t) {
+				err = errors.Errorf("type %s not found", r.Type)
+			}
 			if err != nil {
-				fmt.Println("not found " + p)
+				diag.Errorf(r.Pos, diag.UnknownParams, "@Response %d: %s", r.Code, err)
 				continue
 			}
-			api.Parameters = transParams(si.Fields)
-			continue
+			data = orAny(b.schemaOf(t))
+		} else if r.Code == http.StatusOK && wrap {
+			// 只写了说明的 200 响应, 数据仍由返回值推导
+			data = b.resultSchema(sf)
 		}
+		resp.Schema = data
+		if wrap {
+			resp.Schema = envelope(data)
+		}
+		declared = declared || r.Code == http.StatusOK
+		result = append(result, resp)
 	}
-	return api, nil
+	if wrap && !declared {
+		result = append(result, Response{Code: http.StatusOK, Description: "OK", Schema: envelope(b.resultSchema(sf))})
+	}
+	return result
 }
 
-func explode(str string) []string {
-	return strings.Split(str, " ")
+// envelope egin.Response 输出的统一结构, data 为 nil 时不含 data 字段
+func envelope(data *Schema) *Schema {
+	schema := &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"code":    {Type: "integer", Format: "int32", Description: "错误码, 0 为成功"},
+			"message": {Type: "string"},
+		},
+		Required: []string{"code", "message"},
+	}
+	if data != nil {
+		schema.Properties["data"] = data
+	}
+	return schema
 }
 
-func filterByName(info []parser.StructInfo, name string) (result parser.StructInfo, err error) {
-	for _, v := range info {
-		if v.Name == name {
-			return v, nil
+// known 类型能否在已加载的包中找到, 未加载的包(如第三方依赖)中的类型无法判断, 视为存在
+func (b *builder) known(t *parser.Type) bool {
+	t = t.Deref()
+	for t.Kind == parser.KindSlice || t.Kind == parser.KindArray || t.Kind == parser.KindMap {
+		t = t.Elem.Deref()
+	}
+	if t.Kind != parser.KindNamed || t.PkgPath == "" || !b.prog.HasPackage(t.PkgPath) {
+		return true
+	}
+	if _, ok := b.prog.Lookup(t); ok {
+		return true
+	}
+	if _, ok := b.prog.NamedType(t); ok {
+		return true
+	}
+	_, ok := b.prog.Interface(t)
+	return ok
+}
+
+// paramsStruct 未声明 @Params 时, 取处理函数最后一个结构体参数作为请求参数
+func paramsStruct(sf parser.StructFunc, prog *parser.Program) (si parser.StructInfo, ok bool) {
+	if len(sf.Params) < 2 {
+		return si, false
+	}
+	return prog.Lookup(sf.Params[len(sf.Params)-1].Type)
+}
+
+// resultSchema 处理函数第一个返回值的结构, 返回 interface{} 时无法确定, 为任意类型
+func (b *builder) resultSchema(sf parser.StructFunc) *Schema {
+	if len(sf.Results) == 0 {
+		return nil
+	}
+	return orAny(b.schemaOf(sf.Results[0].Type))
+}
+
+// transParams 按 gin 绑定时使用的标签拆分参数结构体的字段: uri 为路径参数, header 为请求头;
+// POST, PUT, PATCH 的其余字段组成请求体, 全部只有 form 标签时为表单, 否则为 JSON; 其他方法为 query 参数.
+// in 标签可显式指定位置. 全部字段都在 JSON 请求体中时, 请求体直接引用参数结构体的定义
+func (b *builder) transParams(si parser.StructInfo, method string) (ps []Parameter, body *Body) {
+	hasBody := method == "POST" || method == "PUT" || method == "PATCH"
+	var inBody []parser.StructField
+	form := true
+	fields := 0
+	for _, v := range si.Fields {
+		if !isProperty(v) {
+			continue
+		}
+		fields++
+		param := b.transParam(v, hasBody)
+		switch param.In {
+		case "body":
+			form = false
+			inBody = append(inBody, v)
+		case "formData":
+			inBody = append(inBody, v)
+		default:
+			ps = append(ps, param)
 		}
 	}
-	return result, errors.New("not found")
+	if len(inBody) == 0 {
+		return ps, nil
+	}
+
+	body = &Body{ContentType: "application/json"}
+	in := "body"
+	if form {
+		body.ContentType = "application/x-www-form-urlencoded"
+		in = "formData"
+	}
+	body.Schema = &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	for _, v := range inBody {
+		name := paramName(v, in)
+		body.Schema.Properties[name] = b.fieldSchema(v)
+		if isRequired(v) {
+			body.Schema.Required = append(body.Schema.Required, name)
+			body.Required = true
+		}
+	}
+	if !form && len(inBody) == fields {
+		body.Schema = b.definition(si)
+	}
+	return ps, body
 }
 
-func transParams(fields []parser.StructField) (ps []Parameter) {
-	for _, v := range fields {
-		ps = append(ps, transParam(v))
+func (b *builder) transParam(field parser.StructField, hasBody bool) (param Parameter) {
+	param.Schema = b.fieldSchema(field)
+	param.Description, param.Schema.Description = param.Schema.Description, ""
+	param.In = paramIn(field, hasBody)
+	param.Name = paramName(field, param.In)
+	param.Required = isRequired(field) || param.In == "path"
+	return param
+}
+
+// paramIn 字段在请求中的位置, 与 gin 绑定时使用的标签一致
+func paramIn(field parser.StructField, hasBody bool) string {
+	switch {
+	case field.Tags["in"] != "":
+		return field.Tags["in"]
+	case tagName(field, "uri") != "":
+		return "path"
+	case tagName(field, "header") != "":
+		return "header"
+	case !hasBody:
+		return "query"
+	case tagName(field, "form") != "" && tagName(field, "json") == "":
+		return "formData"
 	}
-	return ps
+	return "body"
 }
 
-func transParam(field parser.StructField) (param Parameter) {
-	param.Type = field.Type
-	if name, ok := field.Tags["json"]; ok {
-		param.Name = name
+// paramName 字段在指定位置时使用的名称, 对应位置的绑定标签优先, 其次为 json 标签
+func paramName(field parser.StructField, in string) string {
+	key := map[string]string{"path": "uri", "header": "header", "query": "form", "formData": "form"}[in]
+	if name := tagName(field, key); key != "" && name != "" {
+		return name
 	}
-	if label, ok := field.Tags["label"]; ok {
-		param.Description = label
+	return propertyName(field)
+}
+
+func tagName(field parser.StructField, key string) string {
+	name := strings.Split(field.Tags[key], ",")[0]
+	if name == "-" {
+		return ""
 	}
-	if where, ok := field.Tags["in"]; ok {
-		param.In = where
+	return name
+}
+
+// isProperty 字段是否出现在文档中, 跳过未导出字段, json:"-" 以及无法提升的匿名嵌入字段
+func isProperty(field parser.StructField) bool {
+	if !token.IsExported(field.Name) || field.Tags["json"] == "-" {
+		return false
+	}
+	return !field.Embedded || strings.Split(field.Tags["json"], ",")[0] != ""
+}
+
+// propertyName 字段在文档中的名称, 优先取 json 标签
+func propertyName(field parser.StructField) string {
+	if name := strings.Split(field.Tags["json"], ",")[0]; name != "" {
+		return name
 	}
+	return field.Name
+}
+
+func isRequired(field parser.StructField) bool {
 	if binding, ok := field.Tags["binding"]; ok {
 		rules := strings.Split(binding, ",")
 		if _, ok := lib.Find(rules, "required"); ok {
-			param.Required = true
+			return true
 		}
 	}
-	return param
+	return false
 }
 
-func Filter(info []parser.StructInfo) (paths Paths, tags []Tags) {
-	paths = make(Paths)
+// Filter 将控制器中带有路由注解的方法加入 spec
+func Filter(spec *Spec, info []parser.StructInfo, prog *parser.Program) {
+	b := &builder{spec: spec, prog: prog}
 	for _, v := range info {
-		c := transController(v)
+		c, err := transController(v)
+		diag.Report(diag.Error, diag.MalformedAnnotation, err)
 		if v.Funcs != nil {
 			for _, f := range v.Funcs {
-				api, err := transApi(f, info)
+				api, err := b.transApi(f, v, c)
+				if err != nil && err != annotation.ErrNotApi {
+					diag.Report(diag.Error, diag.MalformedAnnotation, err)
+				}
 				if err == nil {
-					api.Tags = []string{c.Tag}
-					path := Path(api.Path)
-					method := Method(strings.ToLower(api.Method))
-					if _, ok := paths[path]; !ok {
-						paths[path] = make(map[Method]Api)
+					if len(api.Tags) == 0 {
+						api.Tags = []string{c.Tag}
+					}
+					if spec.Paths.Add(api) {
+						spec.addOperation(api)
 					}
-					paths[path][method] = api
 				}
 			}
-			tags = append(tags, Tags{Name: c.Tag, Description: c.Desc})
+			spec.Tags = append(spec.Tags, Tags{Name: c.Tag, Description: c.Desc})
+		}
+	}
+}
+
+// addOperation 登记接口的 operationId, 整个文档中重复时记录错误
+func (s *Spec) addOperation(api Api) {
+	if api.OperationId == "" {
+		return
+	}
+	if exist, ok := s.operations[api.OperationId]; ok {
+		diag.Errorf(api.Pos, diag.DuplicateOperationId, "duplicate operationId %s of %s %s, first used by %s %s at %s",
+			api.OperationId, api.Method, api.Path, exist.Method, exist.Path, exist.Pos)
+		return
+	}
+	s.operations[api.OperationId] = api
+}
+
+// Add 添加接口, 相同的 method + path 已存在时记录错误并保留先声明的接口, 返回是否已添加
+func (p Paths) Add(api Api) bool {
+	path := Path(api.Path)
+	method := Method(strings.ToLower(api.Method))
+	if _, ok := p[path]; !ok {
+		p[path] = make(map[Method]Api)
+	}
+	if exist, ok := p[path][method]; ok {
+		diag.Errorf(api.Pos, diag.DuplicateRoute, "duplicate route %s %s, first declared at %s", api.Method, api.Path, exist.Pos)
+		return false
+	}
+	p[path][method] = api
+	return true
+}
+
+// Merge 合并其他文件解析出的接口
+func (p Paths) Merge(src Paths) {
+	for _, methods := range src {
+		for _, api := range methods {
+			p.Add(api)
 		}
 	}
-	return paths, tags
 }
@@ -0,0 +1,143 @@
+package swagger
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Patterns 内置的基于正则的校验规则 => 正则表达式, 规则出现在 binding 标签中时作为 pattern 输出.
+// NewSpec 时复制到 Spec.Patterns, 项目中通过 RegisterValidation 注册的自定义规则登记在 Spec.Patterns 中
+var Patterns = map[string]string{
+	"alpha":       `^[a-zA-Z]+$`,
+	"alphanum":    `^[a-zA-Z0-9]+$`,
+	"numeric":     `^[-+]?[0-9]+(?:\.[0-9]+)?$`,
+	"number":      `^[0-9]+$`,
+	"hexadecimal": `^(0[xX])?[0-9a-fA-F]+$`,
+	"hexcolor":    `^#(?:[0-9a-fA-F]{3}|[0-9a-fA-F]{4}|[0-9a-fA-F]{6}|[0-9a-fA-F]{8})$`,
+	"e164":        `^\+[1-9]?[0-9]{7,14}$`,
+}
+
+// formats 校验规则 => 字符串的 format
+var formats = map[string]string{
+	"email":    "email",
+	"url":      "uri",
+	"http_url": "uri",
+	"uri":      "uri",
+	"uuid":     "uuid",
+	"uuid4":    "uuid",
+	"ipv4":     "ipv4",
+	"ipv6":     "ipv6",
+	"hostname": "hostname",
+	"base64":   "byte",
+	"datetime": "date-time",
+}
+
+// oneofValues validator 中 oneof 的取值, 以空格分隔, 含空格的值用单引号包围
+var oneofValues = regexp.MustCompile(`'[^']*'|\S+`)
+
+// applyRules 将 binding 标签中的校验规则转为 Schema 的约束, 无法表示的规则会被忽略.
+// dive 之后的规则作用于数组元素, patterns 为基于正则的规则
+func applyRules(schema *Schema, rules []string, patterns map[string]string) {
+	if schema == nil || schema.Ref != "" {
+		return
+	}
+	for i, rule := range rules {
+		name, param := rule, ""
+		if j := strings.Index(rule, "="); j > 0 {
+			name, param = rule[:j], rule[j+1:]
+		}
+		switch name {
+		case "dive":
+			if schema.Items != nil {
+				applyRules(schema.Items, rules[i+1:], patterns)
+			}
+			return
+		case "min", "gte":
+			applyBound(schema, param, true, false)
+		case "max", "lte":
+			applyBound(schema, param, false, false)
+		case "gt":
+			applyBound(schema, param, true, true)
+		case "lt":
+			applyBound(schema, param, false, true)
+		case "len":
+			applyBound(schema, param, true, false)
+			applyBound(schema, param, false, false)
+		case "oneof":
+			schema.Enum = nil
+			for _, v := range oneofValues.FindAllString(param, -1) {
+				schema.Enum = append(schema.Enum, literal(schema.Type, strings.Trim(v, "'")))
+			}
+		case "startswith":
+			schema.Pattern = "^" + regexp.QuoteMeta(param)
+		case "endswith":
+			schema.Pattern = regexp.QuoteMeta(param) + "$"
+		default:
+			if format, ok := formats[name]; ok && schema.Type == "string" {
+				// datetime 只有使用 RFC3339 格式时才是 date-time
+				if name != "datetime" || param == "2006-01-02T15:04:05Z07:00" {
+					schema.Format = format
+				}
+			} else if pattern, ok := patterns[name]; ok {
+				schema.Pattern = pattern
+			}
+		}
+	}
+}
+
+// applyBound 设置下限或上限, 数值限制取值, 字符串限制长度, 数组限制元素个数.
+// exclusive 为 true 时不含边界, 长度与个数为整数, 边界加减 1 即可
+func applyBound(schema *Schema, param string, lower bool, exclusive bool) {
+	switch schema.Type {
+	case "integer", "number":
+		n, err := strconv.ParseFloat(param, 64)
+		if err != nil {
+			return
+		}
+		if lower {
+			schema.Minimum, schema.ExclusiveMinimum = &n, exclusive
+		} else {
+			schema.Maximum, schema.ExclusiveMaximum = &n, exclusive
+		}
+	case "string", "array":
+		n, err := strconv.Atoi(param)
+		if err != nil {
+			return
+		}
+		if exclusive && lower {
+			n++
+		} else if exclusive {
+			n--
+		}
+		switch {
+		case schema.Type == "string" && lower:
+			schema.MinLength = &n
+		case schema.Type == "string":
+			schema.MaxLength = &n
+		case lower:
+			schema.MinItems = &n
+		default:
+			schema.MaxItems = &n
+		}
+	}
+}
+
+// literal 按字段类型转换 oneof 的取值, 无法转换时保留字符串
+func literal(typ string, v string) interface{} {
+	switch typ {
+	case "integer":
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return n
+		}
+	case "number":
+		if n, err := strconv.ParseFloat(v, 64); err == nil {
+			return n
+		}
+	case "boolean":
+		if b, err := strconv.ParseBool(v); err == nil {
+			return b
+		}
+	}
+	return v
+}
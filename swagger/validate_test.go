@@ -0,0 +1,153 @@
+package swagger
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestApplyRules(t *testing.T) {
+	f := func(v float64) *float64 { return &v }
+	n := func(v int) *int { return &v }
+	patterns := map[string]string{
+		"alpha":  Patterns["alpha"],
+		"mobile": `^1[3-9]\d{9}$`,
+	}
+	tests := []struct {
+		name   string
+		schema *Schema
+		rules  string
+		want   *Schema
+	}{
+		{
+			name:   "integer range",
+			schema: &Schema{Type: "integer"},
+			rules:  "required,min=1,max=100",
+			want:   &Schema{Type: "integer", Minimum: f(1), Maximum: f(100)},
+		},
+		{
+			name:   "exclusive number range",
+			schema: &Schema{Type: "number"},
+			rules:  "gt=0,lt=1.5",
+			want:   &Schema{Type: "number", Minimum: f(0), ExclusiveMinimum: true, Maximum: f(1.5), ExclusiveMaximum: true},
+		},
+		{
+			name:   "string length",
+			schema: &Schema{Type: "string"},
+			rules:  "gte=2,lte=20",
+			want:   &Schema{Type: "string", MinLength: n(2), MaxLength: n(20)},
+		},
+		{
+			name:   "exclusive string length",
+			schema: &Schema{Type: "string"},
+			rules:  "gt=2,lt=20",
+			want:   &Schema{Type: "string", MinLength: n(3), MaxLength: n(19)},
+		},
+		{
+			name:   "exact length",
+			schema: &Schema{Type: "string"},
+			rules:  "len=6",
+			want:   &Schema{Type: "string", MinLength: n(6), MaxLength: n(6)},
+		},
+		{
+			name:   "array items",
+			schema: &Schema{Type: "array", Items: &Schema{Type: "string"}},
+			rules:  "min=1,max=5",
+			want:   &Schema{Type: "array", Items: &Schema{Type: "string"}, MinItems: n(1), MaxItems: n(5)},
+		},
+		{
+			name:   "dive",
+			schema: &Schema{Type: "array", Items: &Schema{Type: "string"}},
+			rules:  "max=5,dive,email,max=64",
+			want:   &Schema{Type: "array", Items: &Schema{Type: "string", Format: "email", MaxLength: n(64)}, MaxItems: n(5)},
+		},
+		{
+			name:   "integer oneof",
+			schema: &Schema{Type: "integer"},
+			rules:  "oneof=1 2 3",
+			want:   &Schema{Type: "integer", Enum: []interface{}{int64(1), int64(2), int64(3)}},
+		},
+		{
+			name:   "quoted string oneof",
+			schema: &Schema{Type: "string"},
+			rules:  "oneof='red green' blue",
+			want:   &Schema{Type: "string", Enum: []interface{}{"red green", "blue"}},
+		},
+		{
+			name:   "format",
+			schema: &Schema{Type: "string"},
+			rules:  "url",
+			want:   &Schema{Type: "string", Format: "uri"},
+		},
+		{
+			name:   "format only for strings",
+			schema: &Schema{Type: "integer"},
+			rules:  "email",
+			want:   &Schema{Type: "integer"},
+		},
+		{
+			name:   "rfc3339 datetime",
+			schema: &Schema{Type: "string"},
+			rules:  "datetime=2006-01-02T15:04:05Z07:00",
+			want:   &Schema{Type: "string", Format: "date-time"},
+		},
+		{
+			name:   "other datetime layout",
+			schema: &Schema{Type: "string"},
+			rules:  "datetime=2006-01-02",
+			want:   &Schema{Type: "string"},
+		},
+		{
+			name:   "prefix",
+			schema: &Schema{Type: "string"},
+			rules:  "startswith=a.b",
+			want:   &Schema{Type: "string", Pattern: `^a\.b`},
+		},
+		{
+			name:   "suffix",
+			schema: &Schema{Type: "string"},
+			rules:  "endswith=.png",
+			want:   &Schema{Type: "string", Pattern: `\.png$`},
+		},
+		{
+			name:   "builtin pattern",
+			schema: &Schema{Type: "string"},
+			rules:  "alpha",
+			want:   &Schema{Type: "string", Pattern: Patterns["alpha"]},
+		},
+		{
+			name:   "custom pattern",
+			schema: &Schema{Type: "string"},
+			rules:  "required,mobile",
+			want:   &Schema{Type: "string", Pattern: `^1[3-9]\d{9}$`},
+		},
+		{
+			name:   "unknown rule and bad param",
+			schema: &Schema{Type: "integer"},
+			rules:  "unknown,min=abc",
+			want:   &Schema{Type: "integer"},
+		},
+		{
+			name:   "reference",
+			schema: &Schema{Ref: "#/definitions/User"},
+			rules:  "min=1",
+			want:   &Schema{Ref: "#/definitions/User"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			applyRules(tt.schema, strings.Split(tt.rules, ","), patterns)
+			if !reflect.DeepEqual(tt.schema, tt.want) {
+				t.Errorf("got %+v, want %+v", tt.schema, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyRulesPatternsPerSpec(t *testing.T) {
+	schema := &Schema{Type: "string"}
+	applyRules(schema, []string{"mobile"}, Patterns)
+	if schema.Pattern != "" {
+		t.Errorf("rule from another spec should be ignored, got pattern %q", schema.Pattern)
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/pkg/errors"
+
+	"github.com/daodao97/egin-tools/diag"
+	"github.com/daodao97/egin-tools/parser"
+)
+
+// debounce 编辑器保存一次文件常产生多个事件, 合并这段时间内的变更后再重新生成
+const debounce = 300 * time.Millisecond
+
+// reload 通知已打开的文档页面刷新
+var reload = &reloader{clients: make(map[chan struct{}]bool)}
+
+// reloadScript 注入到文档页面中, 收到 reload 事件后刷新页面
+const reloadScript = `<script>new EventSource("./events").addEventListener("reload", function () { location.reload() })</script>`
+
+// reloader 以 SSE 向已连接的页面推送 reload 事件
+type reloader struct {
+	mu      sync.Mutex
+	clients map[chan struct{}]bool
+}
+
+func (r *reloader) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	ch := make(chan struct{}, 1)
+	r.mu.Lock()
+	r.clients[ch] = true
+	r.mu.Unlock()
+	defer func() {
+		r.mu.Lock()
+		delete(r.clients, ch)
+		r.mu.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	_, _ = fmt.Fprint(w, ": connected\n\n")
+	flusher.Flush()
+	for {
+		select {
+		case <-req.Context().Done():
+			return
+		case <-ch:
+			_, _ = fmt.Fprint(w, "event: reload\ndata: {}\n\n")
+			flusher.Flush()
+		}
+	}
+}
+
+func (r *reloader) notify() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for ch := range r.clients {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// injectReload 在页面的 </body> 前插入刷新脚本
+func injectReload(page []byte) []byte {
+	i := bytes.LastIndex(page, []byte("</body>"))
+	if i < 0 {
+		return append(page, reloadScript...)
+	}
+	return append(append(append([]byte{}, page[:i]...), reloadScript...), page[i:]...)
+}
+
+// watchSource 监听模块中所有包的目录, go 文件或配置文件变更时重新生成文档
+func watchSource() {
+	w, err := fsnotify.NewWatcher()
+	onErr(errors.Wrap(err, "create watcher"))
+	defer w.Close()
+	watchDirs(w, program())
+
+	var changed <-chan time.Time
+	for {
+		select {
+		case ev, ok := <-w.Events:
+			if !ok {
+				return
+			}
+			if strings.HasSuffix(ev.Name, ".go") || filepath.Base(ev.Name) == filepath.Base(*configFile) {
+				changed = time.After(debounce)
+			}
+		case err, ok := <-w.Errors:
+			if !ok {
+				return
+			}
+			fmt.Fprintln(os.Stderr, "watch:", err)
+		case <-changed:
+			changed = nil
+			if p, ok := rebuild(); ok {
+				watchDirs(w, p)
+				reload.notify()
+			}
+		}
+	}
+}
+
+// watchDirs 监听模块根目录与各个包的目录, 新增的包在下次重新生成后加入
+func watchDirs(w *fsnotify.Watcher, p *parser.Program) {
+	dirs := map[string]bool{p.Dir: true}
+	for _, f := range p.Files {
+		dirs[filepath.Dir(f.Path)] = true
+	}
+	for dir := range dirs {
+		if err := w.Add(dir); err != nil {
+			fmt.Fprintln(os.Stderr, "watch:", err)
+		}
+	}
+}
+
+// rebuild 重新解析并生成文档, 源码无法解析时保留上次生成的文档
+func rebuild() (*parser.Program, bool) {
+	diag.Default.Reset()
+	p, err := loadProgram(".")
+	if err == nil && parseFailed() {
+		err = errors.New("source has parse errors")
+	}
+	var doc interface{}
+	if err == nil {
+		doc, err = buildSwagger(p)
+	}
+	diag.Print(os.Stderr)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		fmt.Println("文档生成失败, 继续使用上次生成的文档")
+		return nil, false
+	}
+	prog = p
+	setApidoc(doc)
+	if err := writeSwagger(doc); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	fmt.Println("文档已更新", time.Now().Format("15:04:05"))
+	return p, true
+}
+
+// parseFailed 是否有源码语法或类型检查错误
+func parseFailed() bool {
+	for _, d := range diag.Default.List() {
+		if d.Severity == diag.Error && d.Code == diag.ParseError {
+			return true
+		}
+	}
+	return false
+}